@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"time"
+
+	"github.com/dhodges/turing_patterns/images"
+	"github.com/dhodges/turing_patterns/turing"
+	"github.com/dhodges/turing_patterns/util"
+)
+
+// runConfig every setting of a run: the simulation, how long it runs and what it writes
+// a -configfile may hold any of them, and flags given on the command line override it
+// config.json records the effective config of each run, so that it can be given to -configfile to reproduce it
+type runConfig struct {
+	turing.Config
+	SaveNth       int
+	Iterations    int
+	Timeout       duration
+	UntilStable   float64
+	StableMetric  string
+	Patience      int
+	CheckpointNth int
+	Statefile     string
+	Outdir        string
+	Rundir        bool
+	PNG           bool
+	APNG          string
+	GIF           string
+	GIFPalette    string
+	GIFQuantizer  string
+	GIFDither     bool
+	Video         string
+	FPS           int
+	VideoChroma   string
+	FrameDelay    duration
+	Loops         int
+}
+
+// duration a time.Duration written in JSON as a string, e.g. "30m"
+type duration time.Duration
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	s := ""
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("durations must be strings such as \"30m\" or \"100ms\": %v", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	*d = duration(parsed)
+	return err
+}
+
+// flagFields the field of this config which holds each flag's setting
+func (cfg *runConfig) flagFields() map[string]interface{} {
+	return map[string]interface{}{
+		"model":         &cfg.Model,
+		"workers":       &cfg.Workers,
+		"seed":          &cfg.Seed,
+		"saveNth":       &cfg.SaveNth,
+		"iterations":    &cfg.Iterations,
+		"timeout":       &cfg.Timeout,
+		"until-stable":  &cfg.UntilStable,
+		"stable-metric": &cfg.StableMetric,
+		"patience":      &cfg.Patience,
+		"checkpointNth": &cfg.CheckpointNth,
+		"statefile":     &cfg.Statefile,
+		"outdir":        &cfg.Outdir,
+		"rundir":        &cfg.Rundir,
+		"png":           &cfg.PNG,
+		"apng":          &cfg.APNG,
+		"gif":           &cfg.GIF,
+		"gif-palette":   &cfg.GIFPalette,
+		"gif-quantizer": &cfg.GIFQuantizer,
+		"gif-dither":    &cfg.GIFDither,
+		"video":         &cfg.Video,
+		"fps":           &cfg.FPS,
+		"video-chroma":  &cfg.VideoChroma,
+		"frame-delay":   &cfg.FrameDelay,
+		"loops":         &cfg.Loops,
+	}
+}
+
+// readFlags copy the value of each flag into this config
+func (cfg *runConfig) readFlags() {
+	for name, field := range cfg.flagFields() {
+		value := flag.Lookup(name).Value.(flag.Getter).Get()
+		switch field := field.(type) {
+		case *string:
+			*field = value.(string)
+		case *int:
+			*field = value.(int)
+		case *int64:
+			*field = value.(int64)
+		case *float64:
+			*field = value.(float64)
+		case *bool:
+			*field = value.(bool)
+		case *duration:
+			*field = duration(value.(time.Duration))
+		}
+	}
+}
+
+// setFlags set each flag, except those given on the command line, to its value in this config
+func (cfg *runConfig) setFlags() {
+	given := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	for name, field := range cfg.flagFields() {
+		if given[name] {
+			continue
+		}
+		value := ""
+		switch field := field.(type) {
+		case *duration:
+			value = time.Duration(*field).String()
+		case *string:
+			value = *field
+		case *int:
+			value = fmt.Sprint(*field)
+		case *int64:
+			value = fmt.Sprint(*field)
+		case *float64:
+			value = fmt.Sprint(*field)
+		case *bool:
+			value = fmt.Sprint(*field)
+		}
+		if err := flag.Set(name, value); err != nil {
+			log.Fatalf("%s: invalid %s: %v", *configfile, name, err)
+		}
+	}
+}
+
+// readConfigFile read the -configfile, if any: the settings it holds apply to each flag not given on the command line
+// return the config of the simulation, whose Model, Seed and Workers are superseded by those flags
+func readConfigFile() turing.Config {
+	if *configfile == "" {
+		return turing.DefaultConfig()
+	}
+	cfg, err := loadRunConfig(*configfile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.setFlags()
+	return cfg.Config
+}
+
+// loadRunConfig read the given config file, any settings it omits are taken from the current flags
+// fields which are not settings, e.g. misspelt ones, are rejected
+func loadRunConfig(filename string) (runConfig, error) {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return runConfig{}, err
+	}
+	cfg := runConfig{Config: turing.DefaultConfig()}
+	cfg.readFlags()
+	if err := images.DecodeConfig(contents, &cfg); err != nil {
+		return runConfig{}, fmt.Errorf("%s: %v", filename, err)
+	}
+	return cfg, nil
+}
+
+// Validate report every problem with this config, as images.ConfigErrors
+func (cfg runConfig) Validate() error {
+	errs := images.ConfigErrors{}
+	check := func(ok bool, problem string) {
+		if !ok {
+			errs = append(errs, problem)
+		}
+	}
+	if err := cfg.Config.Validate(); err != nil {
+		errs = append(errs, err.(images.ConfigErrors)...)
+	}
+	check(cfg.SaveNth >= 1, "SaveNth must be >= 1")
+	check(cfg.Iterations >= 0, "Iterations must be >= 0")
+	check(cfg.Timeout >= 0, "Timeout must be >= 0")
+	check(cfg.UntilStable >= 0, "UntilStable must be >= 0")
+	check(cfg.StableMetric == "mean" || cfg.StableMetric == "max" || cfg.StableMetric == "flips",
+		fmt.Sprintf("StableMetric must be one of [\"mean\" \"max\" \"flips\"], not %q", cfg.StableMetric))
+	check(cfg.Patience >= 1, "Patience must be >= 1")
+	check(cfg.CheckpointNth >= 0, "CheckpointNth must be >= 0")
+	check(cfg.GIFPalette == util.PaletteFrame || cfg.GIFPalette == util.PaletteGlobal,
+		fmt.Sprintf("GIFPalette must be one of %q, not %q", []string{util.PaletteFrame, util.PaletteGlobal}, cfg.GIFPalette))
+	check(cfg.GIFQuantizer == util.QuantizeMedianCut || cfg.GIFQuantizer == util.QuantizeOctree,
+		fmt.Sprintf("GIFQuantizer must be one of %q, not %q", []string{util.QuantizeMedianCut, util.QuantizeOctree}, cfg.GIFQuantizer))
+	check(cfg.FPS >= 1, "FPS must be >= 1")
+	check(cfg.VideoChroma == util.Chroma420 || cfg.VideoChroma == util.Chroma444,
+		fmt.Sprintf("VideoChroma must be one of %q, not %q", []string{util.Chroma420, util.Chroma444}, cfg.VideoChroma))
+	check(cfg.FrameDelay >= 0, "FrameDelay must be >= 0")
+	check(cfg.Loops >= 0, "Loops must be >= 0")
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// runConfigJSON the effective config of this run, as JSON
+func runConfigJSON(sim *turing.Simulation) []byte {
+	cfg := runConfig{}
+	cfg.readFlags()
+	cfg.Config = sim.Config()
+
+	contents, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	return append(contents, '\n')
+}
@@ -1,8 +1,11 @@
 package hsb
 
 import (
+	"fmt"
 	"image/color"
 	"math"
+	"strconv"
+	"strings"
 )
 
 // NHSBA models a non-alpha-premultiplied HSB (== HSV) color with alpha channel
@@ -30,15 +33,37 @@ func NewNHSBA(h, s, b, a float64) *NHSBA {
 }
 
 // ToNRGBA converts to a std NRGBA color
+func (h *NHSBA) ToNRGBA() *color.NRGBA {
+	r, g, b := h.rgb()
+
+	// convert from range [0 <= n <= 1.0] to [0 <= n <= 255]
+
+	return &color.NRGBA{
+		R: uint8(math.Round(r * 255)),
+		G: uint8(math.Round(g * 255)),
+		B: uint8(math.Round(b * 255)),
+		A: uint8(math.Round(h.A * 255)),
+	}
+}
+
+// RGBA implements color.Color, returning the alpha-premultiplied red, green, blue and alpha, each within 0 <= n <= 0xffff
+func (h NHSBA) RGBA() (r, g, b, a uint32) {
+	red, green, blue := h.rgb()
+	alpha := constrain(0.0, h.A, 1.0)
+	premultiply := func(n float64) uint32 {
+		return uint32(math.Round(n * alpha * 0xffff))
+	}
+	return premultiply(red), premultiply(green), premultiply(blue), uint32(math.Round(alpha * 0xffff))
+}
+
+// rgb the red, green and blue of this color, each within 0 <= n <= 1.0
 // see: http://en.wikipedia.org/wiki/HSV_color_space
 // and: https://jsfiddle.net/Lamik/Lr61wqub
-func (h *NHSBA) ToNRGBA() *color.NRGBA {
+func (h *NHSBA) rgb() (r, g, b float64) {
 	c := h.B * h.S
 	k := h.H / 60.0
 	x := c * (1 - math.Abs(math.Mod(k, 2)-1))
 
-	r, g, b := 0.0, 0.0, 0.0
-
 	if 0 <= k && k <= 1 {
 		r, g = c, x
 	}
@@ -59,13 +84,104 @@ func (h *NHSBA) ToNRGBA() *color.NRGBA {
 	}
 
 	m := h.B - c
+	return r + m, g + m, b + m
+}
 
-	// convert from range [0 <= n <= 1.0] to [0 <= n <= 255]
+// fromNRGBA converts from a std NRGBA color, the hue of grays being 0
+func fromNRGBA(c color.NRGBA) NHSBA {
+	return fromRGB(float64(c.R)/0xff, float64(c.G)/0xff, float64(c.B)/0xff, float64(c.A)/0xff)
+}
+
+// FromColor converts from any color, the hue of grays being 0
+// NB: the hue and saturation of a fully transparent color are lost, unless it is non-alpha-premultiplied
+func FromColor(c color.Color) NHSBA {
+	switch c := c.(type) {
+	case NHSBA:
+		return c
+	case color.NRGBA:
+		return fromNRGBA(c)
+	}
+	n := color.NRGBA64Model.Convert(c).(color.NRGBA64)
+	return fromRGB(float64(n.R)/0xffff, float64(n.G)/0xffff, float64(n.B)/0xffff, float64(n.A)/0xffff)
+}
 
-	r = math.Round((r + m) * 255)
-	g = math.Round((g + m) * 255)
-	b = math.Round((b + m) * 255)
-	a := math.Round(h.A * 255)
+// fromRGB the color of the given red, green, blue and alpha, each within 0 <= n <= 1.0
+func fromRGB(r, g, b, a float64) NHSBA {
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	chroma := max - min
 
-	return &color.NRGBA{R: uint8(r), G: uint8(g), B: uint8(b), A: uint8(a)}
+	h := 0.0
+	switch {
+	case chroma == 0:
+	case max == r:
+		h = 60 * math.Mod((g-b)/chroma+6, 6)
+	case max == g:
+		h = 60 * ((b-r)/chroma + 2)
+	default:
+		h = 60 * ((r-g)/chroma + 4)
+	}
+	s := 0.0
+	if max > 0 {
+		s = chroma / max
+	}
+	return NHSBA{H: h, S: s, B: max, A: a}
+}
+
+// Model converts any color to an NHSBA color
+var Model = color.ModelFunc(func(c color.Color) color.Color {
+	return FromColor(c)
+})
+
+// ParseHex parse an opaque color written in hex, e.g. "#ff8000" or "#f80"
+func ParseHex(s string) (NHSBA, error) {
+	digits := strings.TrimPrefix(s, "#")
+	if len(digits) == 3 {
+		digits = string([]byte{digits[0], digits[0], digits[1], digits[1], digits[2], digits[2]})
+	}
+	rgb, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || len(digits) != 6 {
+		return NHSBA{}, fmt.Errorf("invalid hex color %q, expected e.g. \"#ff8000\"", s)
+	}
+	return fromNRGBA(color.NRGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 255}), nil
+}
+
+// Blend return the color the given fraction, 0 <= t <= 1, of the way from one color to another
+// the hue takes the shorter way around the color wheel
+func Blend(from, to NHSBA, t float64) NHSBA {
+	dh := to.H - from.H
+	if dh > 180 {
+		dh -= 360
+	} else if dh < -180 {
+		dh += 360
+	}
+	return NHSBA{
+		H: math.Mod(from.H+dh*t+360, 360),
+		S: from.S + (to.S-from.S)*t,
+		B: from.B + (to.B-from.B)*t,
+		A: from.A + (to.A-from.A)*t,
+	}
+}
+
+// Parse parse an opaque color written in hex, e.g. "#ff8000", or as HSB, e.g. "hsb(30, 1, 1)"
+func Parse(s string) (NHSBA, error) {
+	if !strings.HasPrefix(s, "hsb(") {
+		return ParseHex(s)
+	}
+	fields := strings.Split(strings.TrimSuffix(strings.TrimPrefix(s, "hsb("), ")"), ",")
+	values := make([]float64, len(fields))
+	for i, field := range fields {
+		value, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return NHSBA{}, fmt.Errorf("invalid HSB color %q, expected e.g. \"hsb(30, 1, 1)\"", s)
+		}
+		values[i] = value
+	}
+	if len(values) != 3 || !strings.HasSuffix(s, ")") {
+		return NHSBA{}, fmt.Errorf("invalid HSB color %q, expected e.g. \"hsb(30, 1, 1)\"", s)
+	}
+	if values[0] < 0 || values[0] > 360 || values[1] < 0 || values[1] > 1 || values[2] < 0 || values[2] > 1 {
+		return NHSBA{}, fmt.Errorf("invalid HSB color %q, expected 0 <= hue <= 360 and 0 <= saturation, brightness <= 1", s)
+	}
+	return NHSBA{H: values[0], S: values[1], B: values[2], A: 1}, nil
 }
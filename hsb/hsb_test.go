@@ -2,7 +2,9 @@ package hsb
 
 import (
 	"fmt"
+	"image"
 	"image/color"
+	"image/draw"
 	"testing"
 )
 
@@ -31,3 +33,141 @@ func TestToRGBAValues(t *testing.T) {
 	testNHSBAtoNRGBA(t, NewNHSBA(200, 0.75, 0.75, 1.0), &color.NRGBA{48, 143, 191, 255})
 	testNHSBAtoNRGBA(t, NewNHSBA(100, 0.5, 0.5, 1.0), &color.NRGBA{85, 128, 64, 255})
 }
+
+func TestFromColor(t *testing.T) {
+	for _, rgba := range []color.NRGBA{{255, 0, 0, 255}, {48, 143, 191, 255}, {85, 128, 64, 255}, {200, 30, 120, 128}, {90, 90, 90, 255}, {0, 0, 0, 0}} {
+		h := FromColor(rgba)
+		testNHSBAtoNRGBA(t, &h, &rgba)
+	}
+	if h := FromColor(color.NRGBA{255, 0, 255, 255}); h != (NHSBA{H: 300, S: 1, B: 1, A: 1}) {
+		t.Errorf("FromColor(magenta) is %+v, but it should be hsb(300, 1, 1)", h)
+	}
+	if h := FromColor(color.Gray{90}); h.H != 0 || h.S != 0 {
+		t.Errorf("FromColor(gray 90) is %+v, but a gray should have a hue and saturation of 0", h)
+	}
+}
+
+func TestRoundTripAcrossTheColorCube(t *testing.T) {
+	for r := 0; r <= 255; r += 15 {
+		for g := 0; g <= 255; g += 15 {
+			for b := 0; b <= 255; b += 15 {
+				for _, a := range []uint8{255, 128, 0} {
+					rgba := color.NRGBA{uint8(r), uint8(g), uint8(b), a}
+					h := FromColor(rgba)
+					testNHSBAtoNRGBA(t, &h, &rgba)
+					// the same color, converted without the NRGBA fast path
+					rgba64 := color.NRGBA64{uint16(r * 257), uint16(g * 257), uint16(b * 257), uint16(a) * 257}
+					if h != FromColor(rgba64) {
+						t.Errorf("FromColor(%v) is %+v, but it should be %+v as for %v", rgba64, FromColor(rgba64), h, rgba)
+					}
+					if a == 255 {
+						testRGBA(t, h, rgba)
+					}
+				}
+
+				rgba64 := color.NRGBA64{uint16(r * 257), uint16(g * 257), uint16(b*257) ^ 0x5a, 0xffff}
+				testRGBA(t, FromColor(rgba64), rgba64)
+			}
+		}
+	}
+}
+
+// testRGBA does the given NHSBA color have the same RGBA values as the given color?
+func testRGBA(t *testing.T, h NHSBA, c color.Color) {
+	r0, g0, b0, a0 := h.RGBA()
+	r1, g1, b1, a1 := c.RGBA()
+	if r0 != r1 || g0 != g1 || b0 != b1 || a0 != a1 {
+		t.Errorf("NHSBA(%v, %v, %v, %v).RGBA() is (%d, %d, %d, %d), but it should be (%d, %d, %d, %d) as for %v",
+			h.H, h.S, h.B, h.A, r0, g0, b0, a0, r1, g1, b1, a1, c)
+	}
+}
+
+func TestModel(t *testing.T) {
+	converted := Model.Convert(color.RGBA{0, 0, 128, 128})
+	if h, ok := converted.(NHSBA); !ok || h != (NHSBA{H: 240, S: 1, B: 1, A: 128.0 / 255}) {
+		t.Errorf("premultiplied half transparent blue converts to %#v", converted)
+	}
+	h := NHSBA{H: 100, S: 0.5, B: 0.5, A: 1}
+	if Model.Convert(h) != h {
+		t.Errorf("an NHSBA color should convert to itself")
+	}
+}
+
+func TestImage(t *testing.T) {
+	bounds := image.Rect(-2, 3, 14, 13)
+	src := image.NewNRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{uint8(x * 16), uint8(y * 20), uint8(x * y), 255})
+		}
+	}
+
+	img := NewImage(bounds)
+	draw.Draw(img, bounds, src, bounds.Min, draw.Src)
+	if !img.Opaque() {
+		t.Errorf("an image drawn from an opaque one should be opaque")
+	}
+	dst := image.NewNRGBA(bounds)
+	draw.Draw(dst, bounds, img, bounds.Min, draw.Src)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if dst.NRGBAAt(x, y) != src.NRGBAAt(x, y) {
+				t.Fatalf("pixel (%d, %d) is %v after drawing through an hsb.Image, but it should be %v", x, y, dst.NRGBAAt(x, y), src.NRGBAAt(x, y))
+			}
+		}
+	}
+
+	sub := img.SubImage(image.Rect(4, 5, 20, 8)).(*Image)
+	if sub.Bounds() != image.Rect(4, 5, 14, 8) || sub.NHSBAAt(6, 7) != img.NHSBAAt(6, 7) {
+		t.Errorf("sub image %v should share the pixels of the image within its bounds", sub.Bounds())
+	}
+	sub.SetNHSBA(6, 7, NHSBA{H: 10, S: 1, B: 1, A: 1})
+	if img.NHSBAAt(6, 7) != (NHSBA{H: 10, S: 1, B: 1, A: 1}) {
+		t.Errorf("setting a pixel of a sub image should set that of the image")
+	}
+	if img.NHSBAAt(100, 100) != (NHSBA{}) {
+		t.Errorf("a pixel outside the image should be transparent black")
+	}
+}
+
+func TestParseHex(t *testing.T) {
+	for hex, rgba := range map[string]color.NRGBA{"#ff8000": {255, 128, 0, 255}, "30bf8f": {48, 191, 143, 255}, "#fff": {255, 255, 255, 255}} {
+		h, err := ParseHex(hex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testNHSBAtoNRGBA(t, &h, &rgba)
+	}
+	for _, hex := range []string{"", "#ff80", "#gg8000", "orange"} {
+		if _, err := ParseHex(hex); err == nil {
+			t.Errorf("%q should not parse as a hex color", hex)
+		}
+	}
+}
+
+func TestBlend(t *testing.T) {
+	from, to := NHSBA{H: 350, S: 0, B: 1, A: 1}, NHSBA{H: 30, S: 1, B: 0.5, A: 1}
+	if blend := Blend(from, to, 0.5); blend != (NHSBA{H: 10, S: 0.5, B: 0.75, A: 1}) {
+		t.Errorf("halfway blend is %+v, but it should take the shorter way around the hues", blend)
+	}
+	if blend := Blend(from, to, 1); blend != to {
+		t.Errorf("full blend is %+v, but it should be %+v", blend, to)
+	}
+}
+
+func TestParse(t *testing.T) {
+	for s, expected := range map[string]NHSBA{
+		"#ff0000":         {H: 0, S: 1, B: 1, A: 1},
+		"hsb(30, 0.5, 1)": {H: 30, S: 0.5, B: 1, A: 1},
+		"hsb(200,1,0.25)": {H: 200, S: 1, B: 0.25, A: 1},
+	} {
+		if actual, err := Parse(s); err != nil || actual != expected {
+			t.Errorf("%q parses as %+v (%v), but it should be %+v", s, actual, err, expected)
+		}
+	}
+	for _, s := range []string{"hsb(30, 0.5)", "hsb(30, 0.5, 1", "hsb(400, 0.5, 1)", "hsb(a, b, c)", "red"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("%q should not parse as a color", s)
+		}
+	}
+}
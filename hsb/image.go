@@ -0,0 +1,88 @@
+package hsb
+
+import (
+	"image"
+	"image/color"
+)
+
+// Image an in-memory image of NHSBA colors, like image.NRGBA
+type Image struct {
+	Pix    []NHSBA         // the pixels, row by row, the pixel at (x, y) being Pix[(y-Rect.Min.Y)*Stride+(x-Rect.Min.X)]
+	Stride int             // the number of pixels between vertically adjacent pixels
+	Rect   image.Rectangle // the image's bounds
+}
+
+// NewImage return a new Image with the given bounds, every pixel of which is transparent black
+func NewImage(r image.Rectangle) *Image {
+	return &Image{
+		Pix:    make([]NHSBA, r.Dx()*r.Dy()),
+		Stride: r.Dx(),
+		Rect:   r,
+	}
+}
+
+// ColorModel implements image.Image
+func (p *Image) ColorModel() color.Model {
+	return Model
+}
+
+// Bounds implements image.Image
+func (p *Image) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+// At implements image.Image
+func (p *Image) At(x, y int) color.Color {
+	return p.NHSBAAt(x, y)
+}
+
+// NHSBAAt the color of the pixel at x, y, or transparent black outside the image's bounds
+func (p *Image) NHSBAAt(x, y int) NHSBA {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return NHSBA{}
+	}
+	return p.Pix[p.PixOffset(x, y)]
+}
+
+// PixOffset the index within Pix of the pixel at x, y
+func (p *Image) PixOffset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.Stride + (x - p.Rect.Min.X)
+}
+
+// Set implements draw.Image, converting the given color to NHSBA
+func (p *Image) Set(x, y int, c color.Color) {
+	p.SetNHSBA(x, y, FromColor(c))
+}
+
+// SetNHSBA set the color of the pixel at x, y, unless it is outside the image's bounds
+func (p *Image) SetNHSBA(x, y int, c NHSBA) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	p.Pix[p.PixOffset(x, y)] = c
+}
+
+// SubImage return the part of this image within the given bounds, sharing its pixels
+func (p *Image) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	if r.Empty() {
+		return &Image{}
+	}
+	return &Image{
+		Pix:    p.Pix[p.PixOffset(r.Min.X, r.Min.Y):],
+		Stride: p.Stride,
+		Rect:   r,
+	}
+}
+
+// Opaque is every pixel of this image fully opaque?
+func (p *Image) Opaque() bool {
+	for y := p.Rect.Min.Y; y < p.Rect.Max.Y; y++ {
+		for x := p.Rect.Min.X; x < p.Rect.Max.X; x++ {
+			if p.Pix[p.PixOffset(x, y)].A < 1 {
+				return false
+			}
+		}
+	}
+	return true
+}
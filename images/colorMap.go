@@ -0,0 +1,174 @@
+package images
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/dhodges/turing_patterns/hsb"
+)
+
+// ColorMap a gradient which colors the values of a grayscale image, from -1 at its first stop to +1 at its last
+// the zero value is a ramp from black to white
+type ColorMap struct {
+	Name          string   // a built-in gradient, see colorMaps, instead of Stops
+	Stops         []string // evenly spaced colors, each in hex e.g. "#ff8000" or HSB e.g. "hsb(30, 1, 1)"
+	Interpolation string   // how colors are mixed between stops: interpolateRGB (default), interpolateHSB or interpolateOKLab
+}
+
+// how colors are mixed between the stops of a ColorMap
+const (
+	interpolateRGB   = "rgb"   // in a straight line through sRGB
+	interpolateHSB   = "hsb"   // hue, saturation and brightness in straight lines, the hue taking the shorter way around
+	interpolateOKLab = "oklab" // in a straight line through a perceptually uniform space, see https://bottosson.github.io/posts/oklab/
+)
+
+// colorMaps the stops of each built-in gradient, approximating those of matplotlib
+// see: https://bids.github.io/colormap/
+var colorMaps = map[string][]string{
+	"gray":     {"#000000", "#ffffff"},
+	"viridis":  {"#440154", "#472d7b", "#3b528b", "#2c728e", "#21918c", "#28ae80", "#5ec962", "#addc30", "#fde725"},
+	"magma":    {"#000004", "#1c1044", "#4f127b", "#812581", "#b5367a", "#e55064", "#fb8761", "#fec287", "#fcfdbf"},
+	"inferno":  {"#000004", "#1f0c48", "#550f6d", "#88226a", "#ba3655", "#e35933", "#f98e09", "#f9cb35", "#fcffa4"},
+	"twilight": {"#e2d9e2", "#9ebbc9", "#6785be", "#5e43a5", "#2f1436", "#6d2355", "#b1524a", "#d3a07f", "#e2d9e2"}, // cyclic
+}
+
+// colorMapNames the names of all built-in gradients, in alphabetical order
+func colorMapNames() []string {
+	names := make([]string, 0, len(colorMaps))
+	for name := range colorMaps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// stops the colors of this gradient
+func (cm ColorMap) stops() ([]hsb.NHSBA, error) {
+	stops := cm.Stops
+	if cm.Name != "" {
+		var ok bool
+		if stops, ok = colorMaps[cm.Name]; !ok {
+			return nil, fmt.Errorf("unknown color map %q, expected one of %v", cm.Name, colorMapNames())
+		}
+	}
+	if len(stops) == 0 {
+		stops = colorMaps["gray"]
+	}
+	if len(stops) < 2 {
+		return nil, fmt.Errorf("a color map needs at least 2 stops")
+	}
+
+	colors := make([]hsb.NHSBA, len(stops))
+	for i, stop := range stops {
+		c, err := hsb.Parse(stop)
+		if err != nil {
+			return nil, fmt.Errorf("stop %d: %v", i, err)
+		}
+		colors[i] = c
+	}
+	return colors, nil
+}
+
+// lut a lookup table of this gradient's color at each of 256 evenly spaced values, from -1 to +1
+func (cm ColorMap) lut() ([]color.NRGBA, error) {
+	stops, err := cm.stops()
+	if err != nil {
+		return nil, err
+	}
+	var mix func(from, to hsb.NHSBA, t float64) color.NRGBA
+	switch cm.Interpolation {
+	case "", interpolateRGB:
+		mix = mixRGB
+	case interpolateHSB:
+		mix = func(from, to hsb.NHSBA, t float64) color.NRGBA {
+			c := hsb.Blend(from, to, t)
+			return *c.ToNRGBA()
+		}
+	case interpolateOKLab:
+		mix = mixOKLab
+	default:
+		return nil, fmt.Errorf("unknown interpolation %q, expected %q, %q or %q", cm.Interpolation, interpolateRGB, interpolateHSB, interpolateOKLab)
+	}
+
+	lut := make([]color.NRGBA, 256)
+	for i := range lut {
+		position := float64(i) / 255 * float64(len(stops)-1)
+		n := int(position)
+		if n == len(stops)-1 {
+			n--
+		}
+		lut[i] = mix(stops[n], stops[n+1], position-float64(n))
+	}
+	return lut, nil
+}
+
+// mixRGB the color the given fraction of the way between two colors, in sRGB
+func mixRGB(from, to hsb.NHSBA, t float64) color.NRGBA {
+	c0, c1 := from.ToNRGBA(), to.ToNRGBA()
+	mix := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*t))
+	}
+	return color.NRGBA{R: mix(c0.R, c1.R), G: mix(c0.G, c1.G), B: mix(c0.B, c1.B), A: mix(c0.A, c1.A)}
+}
+
+// mixOKLab the color the given fraction of the way between two colors, in OKLab
+func mixOKLab(from, to hsb.NHSBA, t float64) color.NRGBA {
+	c0, c1 := from.ToNRGBA(), to.ToNRGBA()
+	l0, a0, b0 := toOKLab(*c0)
+	l1, a1, b1 := toOKLab(*c1)
+	c := fromOKLab(l0+(l1-l0)*t, a0+(a1-a0)*t, b0+(b1-b0)*t)
+	c.A = uint8(math.Round(float64(c0.A) + (float64(c1.A)-float64(c0.A))*t))
+	return c
+}
+
+// toOKLab convert an sRGB color to OKLab, see https://bottosson.github.io/posts/oklab/
+func toOKLab(c color.NRGBA) (l, a, b float64) {
+	r, g, bl := toLinear(c.R), toLinear(c.G), toLinear(c.B)
+	lms := [3]float64{
+		math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*bl),
+		math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*bl),
+		math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*bl),
+	}
+	l = 0.2104542553*lms[0] + 0.7936177850*lms[1] - 0.0040720468*lms[2]
+	a = 1.9779984951*lms[0] - 2.4285922050*lms[1] + 0.4505937099*lms[2]
+	b = 0.0259040371*lms[0] + 0.7827717662*lms[1] - 0.8086757660*lms[2]
+	return l, a, b
+}
+
+// fromOKLab convert an OKLab color to an opaque sRGB color, clipping it to the sRGB gamut
+func fromOKLab(l, a, b float64) color.NRGBA {
+	cube := func(n float64) float64 { return n * n * n }
+	lms := [3]float64{
+		cube(l + 0.3963377774*a + 0.2158037573*b),
+		cube(l - 0.1055613458*a - 0.0638541728*b),
+		cube(l - 0.0894841775*a - 1.2914855480*b),
+	}
+	return color.NRGBA{
+		R: fromLinear(4.0767416621*lms[0] - 3.3077115913*lms[1] + 0.2309699292*lms[2]),
+		G: fromLinear(-1.2684380046*lms[0] + 2.6097574011*lms[1] - 0.3413193965*lms[2]),
+		B: fromLinear(-0.0041960863*lms[0] - 0.7034186147*lms[1] + 1.7076147010*lms[2]),
+		A: 255,
+	}
+}
+
+// toLinear convert an sRGB channel to linear light, between 0 and 1
+func toLinear(c uint8) float64 {
+	n := float64(c) / 255
+	if n <= 0.04045 {
+		return n / 12.92
+	}
+	return math.Pow((n+0.055)/1.055, 2.4)
+}
+
+// fromLinear convert linear light to an sRGB channel
+func fromLinear(n float64) uint8 {
+	n = math.Max(0, math.Min(n, 1))
+	if n <= 0.0031308 {
+		n *= 12.92
+	} else {
+		n = 1.055*math.Pow(n, 1/2.4) - 0.055
+	}
+	return uint8(math.Round(n * 255))
+}
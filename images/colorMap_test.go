@@ -0,0 +1,92 @@
+package images
+
+import (
+	"context"
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+func TestColorMapLUT(t *testing.T) {
+	gray, err := ColorMap{}.lut()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range gray {
+		if c != (color.NRGBA{uint8(i), uint8(i), uint8(i), 255}) {
+			t.Fatalf("the default color map at %d is %v, but it should be gray", i, c)
+		}
+	}
+
+	for _, interpolation := range []string{interpolateRGB, interpolateHSB, interpolateOKLab} {
+		lut, err := ColorMap{Name: "viridis", Interpolation: interpolation}.lut()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lut[0] != (color.NRGBA{0x44, 0x01, 0x54, 255}) || lut[255] != (color.NRGBA{0xfd, 0xe7, 0x25, 255}) {
+			t.Errorf("%s viridis runs from %v to %v, but it should run from its first stop to its last", interpolation, lut[0], lut[255])
+		}
+	}
+
+	// red to magenta: the shorter way around the hues passes through pink, not green
+	stops := []string{"#ff0000", "hsb(300, 1, 1)"}
+	hsbLUT, _ := ColorMap{Stops: stops, Interpolation: interpolateHSB}.lut()
+	if middle := hsbLUT[128]; middle.R != 255 || middle.G != 0 || middle.B < 100 {
+		t.Errorf("the hsb middle of red and magenta is %v, but it should be pink", middle)
+	}
+	rgbLUT, _ := ColorMap{Stops: stops}.lut()
+	if middle := rgbLUT[128]; middle != (color.NRGBA{255, 0, 128, 255}) {
+		t.Errorf("the rgb middle of red and magenta is %v, but it should be %v", middle, color.NRGBA{255, 0, 128, 255})
+	}
+}
+
+func TestColorMapRejectsInvalidStops(t *testing.T) {
+	for _, cm := range []ColorMap{
+		{Name: "jet"},
+		{Stops: []string{"#ff0000"}},
+		{Stops: []string{"#ff0000", "blue"}},
+		{Stops: []string{"#ff0000", "#0000ff"}, Interpolation: "lch"},
+	} {
+		if _, err := cm.lut(); err == nil {
+			t.Errorf("%+v should be rejected", cm)
+		}
+	}
+}
+
+func TestOKLabRoundTrip(t *testing.T) {
+	for _, c := range []color.NRGBA{{0, 0, 0, 255}, {255, 255, 255, 255}, {255, 128, 0, 255}, {13, 200, 97, 255}} {
+		if actual := fromOKLab(toOKLab(c)); actual != c {
+			t.Errorf("%v is %v after converting to OKLab and back", c, actual)
+		}
+	}
+}
+
+func TestRecolorSavedState(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "state.bin")
+	img := MakeTSImageGray(16, 12, 3)
+	img.NextIteration(context.Background())
+	if err := img.SaveState(filename, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed := MakeTSImageGray(2, 2, 0)
+	if _, err := resumed.LoadState(filename); err != nil {
+		t.Fatal(err)
+	}
+	magma := ColorMap{Name: "magma", Interpolation: interpolateOKLab}
+	if err := resumed.SetColorMap(magma); err != nil {
+		t.Fatal(err)
+	}
+	lut, _ := magma.lut()
+	gray, colored := img.pixmap(), resumed.pixmap()
+	for y := range gray {
+		for x := range gray[y] {
+			if colored[y][x] != lut[gray[y][x].R] {
+				t.Fatalf("recolored pixel (%d, %d) is %v, but it should be %v", x, y, colored[y][x], lut[gray[y][x].R])
+			}
+		}
+	}
+	if resumed.Config().ColorMap.Name != "magma" {
+		t.Errorf("the effective color map is %+v, but it should be magma", resumed.Config().ColorMap)
+	}
+}
@@ -0,0 +1,219 @@
+package images
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/dhodges/turing_patterns/hsb"
+	"github.com/dhodges/turing_patterns/util"
+)
+
+// pixel the state of one pixel of an RGB image after an iteration, from which its next color is chosen
+type pixel struct {
+	color  hsb.NHSBA // its previous color
+	value  float64   // its grid value, -1.0 <= value <= 1.0
+	delta  float64   // the change in its value in the latest iteration, -1.0 <= delta <= 1.0
+	winner int       // the index of the scale which changed its value in the latest iteration
+}
+
+// colorMode return the next color of the given pixel
+type colorMode func(p pixel) hsb.NHSBA
+
+// colorModes make each named color mode from its JSON params and the image's scales, see TSGridConfig.ColorMode
+var colorModes = map[string]func(params json.RawMessage, scales []turingScale) (colorMode, error){
+	"delta-hue":        makeDeltaHue,
+	"grainy":           makeGrainy,
+	"value-hue":        makeValueHue,
+	"value-brightness": makeValueBrightness,
+	"blend":            makeScaleBlend,
+}
+
+// colorModeNames the names of all color modes, in alphabetical order
+func colorModeNames() []string {
+	names := make([]string, 0, len(colorModes))
+	for name := range colorModes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// colorModeOf the name of the color mode of the given config
+// by default, "blend" if any of its scales has a Color, otherwise "delta-hue"
+func colorModeOf(cfg TSGridConfig) string {
+	if cfg.ColorMode != "" {
+		return cfg.ColorMode
+	}
+	if hasScaleColors(cfg.Scales) {
+		return "blend"
+	}
+	return "delta-hue"
+}
+
+// colorParamsOf the params of the color mode of the given config
+// NB: a ColorBlend becomes the Rate param of the "blend" color mode
+func colorParamsOf(cfg TSGridConfig) json.RawMessage {
+	if cfg.ColorBlend == 0 || hasColorParams(cfg) || colorModeOf(cfg) != "blend" {
+		return cfg.ColorParams
+	}
+	params, _ := json.Marshal(struct{ Rate float64 }{cfg.ColorBlend})
+	return params
+}
+
+// hasColorParams does the given config have params for its color mode?
+// NB: a JSON null, as written for a config without them, is none
+func hasColorParams(cfg TSGridConfig) bool {
+	return len(cfg.ColorParams) > 0 && string(cfg.ColorParams) != "null"
+}
+
+// hasScaleColors do any of the given scales have a color?
+func hasScaleColors(scales []turingScale) bool {
+	for _, scale := range scales {
+		if scale.Color != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// makeColorMode make the named color mode from its JSON params and the image's scales
+func makeColorMode(name string, params json.RawMessage, scales []turingScale) (colorMode, error) {
+	makeMode, ok := colorModes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown color mode %q, expected one of %v", name, colorModeNames())
+	}
+	mode, err := makeMode(params, scales)
+	if err != nil {
+		return nil, fmt.Errorf("color mode %q: %v", name, err)
+	}
+	return mode, nil
+}
+
+// checkFraction an error naming the given param unless 0 <= value <= 1
+func checkFraction(name string, value float64) error {
+	if value < 0 || value > 1 {
+		return fmt.Errorf("%s must be between 0 and 1", name)
+	}
+	return nil
+}
+
+// makeDeltaHue the hue of each pixel follows the change in its value, the default
+// params: Saturation and Brightness, of every pixel
+func makeDeltaHue(params json.RawMessage, scales []turingScale) (colorMode, error) {
+	p := struct {
+		Saturation float64
+		Brightness float64
+	}{Saturation: 1, Brightness: 1}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if err := checkFraction("Saturation", p.Saturation); err != nil {
+		return nil, err
+	}
+	if err := checkFraction("Brightness", p.Brightness); err != nil {
+		return nil, err
+	}
+	return func(px pixel) hsb.NHSBA {
+		return hsb.NHSBA{H: ((px.delta + 1) / 2) * 360.0, S: p.Saturation, B: p.Brightness, A: 1.0}
+	}, nil
+}
+
+// makeGrainy each pixel walks through HSB space by the change in its value: its hue, unless that is at a limit,
+// otherwise its saturation, otherwise its brightness
+// params: Scale, by which the change in value is multiplied
+func makeGrainy(params json.RawMessage, scales []turingScale) (colorMode, error) {
+	p := struct {
+		Scale float64
+	}{Scale: 100}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	return func(px pixel) hsb.NHSBA {
+		c := px.color
+		delta := util.ToFixed(px.delta*p.Scale, 2)
+		switch {
+		case delta == 0.0:
+		case 0.0 < c.H && c.H < 360.0:
+			c.H = util.Constrain(0.0, c.H+delta, 360.0)
+		case 0.0 < c.S && c.S < 1.0:
+			c.S = util.Constrain(0.0, c.S+delta, 1.0)
+		case 0.0 < c.B && c.B < 1.0:
+			c.B = util.Constrain(0.0, c.B+delta, 1.0)
+		}
+		return c
+	}, nil
+}
+
+// makeValueHue the hue of each pixel follows its value
+// params: HueFrom and HueTo, the hues of the values -1 and +1, and Saturation and Brightness, of every pixel
+func makeValueHue(params json.RawMessage, scales []turingScale) (colorMode, error) {
+	p := struct {
+		HueFrom    float64
+		HueTo      float64
+		Saturation float64
+		Brightness float64
+	}{HueFrom: 0, HueTo: 360, Saturation: 1, Brightness: 1}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if err := checkFraction("Saturation", p.Saturation); err != nil {
+		return nil, err
+	}
+	if err := checkFraction("Brightness", p.Brightness); err != nil {
+		return nil, err
+	}
+	return func(px pixel) hsb.NHSBA {
+		h := p.HueFrom + (px.value+1)/2*(p.HueTo-p.HueFrom)
+		return hsb.NHSBA{H: util.Constrain(0.0, h, 360.0), S: p.Saturation, B: p.Brightness, A: 1.0}
+	}, nil
+}
+
+// makeValueBrightness the brightness of each pixel follows its value, its hue is fixed
+// params: Hue and Saturation, of every pixel
+func makeValueBrightness(params json.RawMessage, scales []turingScale) (colorMode, error) {
+	p := struct {
+		Hue        float64
+		Saturation float64
+	}{Hue: 200, Saturation: 0.75}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Hue < 0 || p.Hue > 360 {
+		return nil, fmt.Errorf("Hue must be between 0 and 360")
+	}
+	if err := checkFraction("Saturation", p.Saturation); err != nil {
+		return nil, err
+	}
+	return func(px pixel) hsb.NHSBA {
+		return hsb.NHSBA{H: p.Hue, S: p.Saturation, B: (px.value + 1) / 2, A: 1.0}
+	}, nil
+}
+
+// makeScaleBlend each pixel moves towards the color of the scale which won there, if it has one
+// params: Rate, the fraction of the way it moves in each iteration
+// NB: scales whose Color is invalid are treated as having none, Validate reports them
+func makeScaleBlend(params json.RawMessage, scales []turingScale) (colorMode, error) {
+	p := struct {
+		Rate float64
+	}{Rate: 0.1}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if err := checkFraction("Rate", p.Rate); err != nil {
+		return nil, err
+	}
+
+	colors := make([]*hsb.NHSBA, len(scales))
+	for k, scale := range scales {
+		if c, err := hsb.Parse(scale.Color); err == nil {
+			colors[k] = &c
+		}
+	}
+	return func(px pixel) hsb.NHSBA {
+		if c := colors[px.winner]; c != nil {
+			return hsb.Blend(px.color, *c, p.Rate)
+		}
+		return px.color
+	}, nil
+}
@@ -0,0 +1,57 @@
+package images
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dhodges/turing_patterns/hsb"
+)
+
+func TestColorModes(t *testing.T) {
+	previous := hsb.NHSBA{H: 100, S: 0.5, B: 0.5, A: 1}
+	px := pixel{color: previous, value: 0.5, delta: -0.5, winner: 1}
+	scales := DefaultScales()[:2]
+	scales[1].Color = "#0000ff"
+
+	tests := []struct {
+		name     string
+		params   string
+		expected hsb.NHSBA
+	}{
+		{"delta-hue", ``, hsb.NHSBA{H: 90, S: 1, B: 1, A: 1}},
+		{"delta-hue", `{"Saturation": 0.5}`, hsb.NHSBA{H: 90, S: 0.5, B: 1, A: 1}},
+		{"grainy", ``, hsb.NHSBA{H: 50, S: 0.5, B: 0.5, A: 1}},
+		{"grainy", `{"Scale": 10}`, hsb.NHSBA{H: 95, S: 0.5, B: 0.5, A: 1}},
+		{"value-hue", `{"HueFrom": 100, "HueTo": 200}`, hsb.NHSBA{H: 175, S: 1, B: 1, A: 1}},
+		{"value-brightness", `{"Hue": 30}`, hsb.NHSBA{H: 30, S: 0.75, B: 0.75, A: 1}},
+		{"blend", `{"Rate": 0.5}`, hsb.NHSBA{H: 170, S: 0.75, B: 0.75, A: 1}},
+	}
+	for _, test := range tests {
+		mode, err := makeColorMode(test.name, json.RawMessage(test.params), scales)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := mode(px); actual != test.expected {
+			t.Errorf("%s %s colors the pixel %+v, but it should be %+v", test.name, test.params, actual, test.expected)
+		}
+	}
+
+	// the first scale has no color to blend towards
+	blend, _ := makeColorMode("blend", nil, scales)
+	if actual := blend(pixel{color: previous, winner: 0}); actual != previous {
+		t.Errorf("blend colors a pixel won by a scale without a color %+v, but it should keep %+v", actual, previous)
+	}
+}
+
+func TestColorModesRejectInvalidParams(t *testing.T) {
+	for name, params := range map[string]string{
+		"sepia":            ``,
+		"delta-hue":        `{"Saturation": 2}`,
+		"value-brightness": `{"Hue": -1}`,
+		"blend":            `{"Rate": 0.5, "Speed": 1}`,
+	} {
+		if _, err := makeColorMode(name, json.RawMessage(params), DefaultScales()); err == nil {
+			t.Errorf("%s %s should be rejected", name, params)
+		}
+	}
+}
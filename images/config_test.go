@@ -0,0 +1,116 @@
+package images
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+const testConfigJSON = `{
+  "Width": 31,
+  "Height": 17,
+  "Seed": 8,
+  "Boundary": "wrap",
+  "Scales": [
+    {"ActivatorRadius": 3, "InhibitorRadius": 6, "SmallAmount": 0.05, "Weight": 1, "Symmetry": 2},
+    {"ActivatorRadius": 1, "InhibitorRadius": 2, "SmallAmount": 0.02, "Weight": 1, "Symmetry": 1}
+  ]
+}`
+
+// checkGridShape does the given grid have the shape, and the scales, of testConfigJSON?
+func checkGridShape(t *testing.T, model string, grid *tsGrid) {
+	if grid.Width != 31 || grid.Height != 17 || len(grid.grid) != 17 || len(grid.grid[0]) != 31 {
+		t.Errorf("%s: grid is %dx%d (%dx%d values), but it should be 31x17", model, grid.Width, grid.Height, len(grid.grid[0]), len(grid.grid))
+	}
+	if len(grid.scales) != 2 || grid.scales[0].InhibitorRadius != 6 || grid.boundary != "wrap" {
+		t.Errorf("%s: grid config is %+v, but it should be that of the config file", model, grid.config())
+	}
+}
+
+// testConfig decode testConfigJSON into the given config
+func testConfig(t *testing.T, cfg interface{}) {
+	if err := DecodeConfig([]byte(testConfigJSON), cfg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewTSImageAppliesTheConfig(t *testing.T) {
+	grayConfig := TSImageConfigGray{}
+	testConfig(t, &grayConfig)
+	gray, err := NewTSImageGray(grayConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGridShape(t, ModelGray, gray.grid)
+	if gray.Seed() != 8 {
+		t.Errorf("gray: seed %d, but it should be 8", gray.Seed())
+	}
+	gray.NextIteration(context.Background())
+
+	rgbConfig := TSImageConfigRGB{}
+	testConfig(t, &rgbConfig)
+	rgb, err := NewTSImageRGB(rgbConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGridShape(t, ModelRGB, rgb.grid)
+	if len(rgb.colors) != 17 || len(rgb.colors[0]) != 31 {
+		t.Errorf("rgb: colors are %dx%d, but they should be 31x17", len(rgb.colors[0]), len(rgb.colors))
+	}
+	rgb.NextIteration(context.Background())
+	if bounds := rgb.Image().Bounds(); bounds.Dx() != 31 || bounds.Dy() != 17 {
+		t.Errorf("rgb: image is %dx%d, but it should be 31x17", bounds.Dx(), bounds.Dy())
+	}
+}
+
+func TestNewTSImageReportsErrors(t *testing.T) {
+	if _, err := NewTSImageGray(TSImageConfigGray{TSGridConfig: TSGridConfig{Width: 8, Height: 8}}); err == nil {
+		t.Errorf("a gray config without scales should be an error")
+	}
+	if _, err := NewTSImageRGB(TSImageConfigRGB{TSGridConfig: TSGridConfig{Width: -8, Height: 8, Scales: DefaultScales()}}); err == nil {
+		t.Errorf("an rgb config with a negative width should be an error")
+	}
+}
+
+func TestConfigFromFileAppliesTheConfig(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(filename, []byte(testConfigJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	gray := MakeTSImageGray(8, 8, 1)
+	gray.SetWorkers(3)
+	if err := gray.ConfigFromFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	checkGridShape(t, ModelGray, gray.grid)
+	if gray.Seed() != 8 || gray.grid.Workers != 3 {
+		t.Errorf("gray: seed %d and workers %d, but they should be 8 and 3", gray.Seed(), gray.grid.Workers)
+	}
+
+	rgb := MakeTSImageRGB(8, 8, 1)
+	if err := rgb.ConfigFromFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	checkGridShape(t, ModelRGB, rgb.grid)
+	if len(rgb.colors) != 17 || len(rgb.colors[0]) != 31 {
+		t.Errorf("rgb: colors are %dx%d, but they should be 31x17", len(rgb.colors[0]), len(rgb.colors))
+	}
+}
+
+func TestConfigFromFileReportsErrors(t *testing.T) {
+	img := MakeTSImageGray(8, 8, 1)
+	if err := img.ConfigFromFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("a missing config file should be an error")
+	}
+
+	filename := filepath.Join(t.TempDir(), "invalid.json")
+	ioutil.WriteFile(filename, []byte(`{"Width": "wide"}`), 0644)
+	if err := img.ConfigFromFile(filename); err == nil {
+		t.Errorf("an invalid config file should be an error")
+	}
+	if img.grid.Width != 8 {
+		t.Errorf("an invalid config file should leave the image unchanged")
+	}
+}
@@ -0,0 +1,178 @@
+package images
+
+import (
+	"github.com/dhodges/turing_patterns/util"
+)
+
+// convolution methods of a turingScale
+const (
+	convolutionAuto   = "auto"   // fft for kernels with radius >= fftRadiusThreshold, otherwise direct
+	convolutionFFT    = "fft"    // always convolve with an FFT
+	convolutionDirect = "direct" // always sum the kernel directly, using prefix sums
+)
+
+// fftRadiusThreshold the smallest kernel radius which uses FFT convolution by default
+// below this the O(radius) prefix sums are faster than a whole-grid FFT
+const fftRadiusThreshold = 32
+
+// fftKernel a kernel whose averages are calculated across the whole grid at once by FFT convolution
+type fftKernel struct {
+	spectrum [][]float64 // NB: the kernel is symmetric, so its spectrum is real
+	counts   [][]float64 // the number of sampled pixels within the kernel at each x, y
+	averages [][]float64 // the average of the current grid within the kernel at each x, y
+}
+
+// usesFFT should the kernel of the given radius of this scale be convolved with an FFT?
+func (scale *turingScale) usesFFT(radius int) bool {
+	switch scale.Convolution {
+	case convolutionFFT:
+		return true
+	case convolutionDirect:
+		return false
+	default:
+		return radius >= fftRadiusThreshold
+	}
+}
+
+// makeFFTKernels precompute the spectrum of every kernel which is convolved with an FFT
+// these are reused for every iteration
+func (grid *tsGrid) makeFFTKernels() {
+	grid.activatorFFTs = make([]*fftKernel, len(grid.scales))
+	grid.inhibitorFFTs = make([]*fftKernel, len(grid.scales))
+
+	maxRadius := -1
+	for k := range grid.scales {
+		scale := &grid.scales[k]
+		for _, radius := range []int{scale.ActivatorRadius, scale.InhibitorRadius} {
+			if scale.usesFFT(radius) && radius > maxRadius {
+				maxRadius = radius
+			}
+		}
+	}
+	if maxRadius < 0 {
+		return
+	}
+
+	// the grid is padded by maxRadius pixels on every side, according to its boundary
+	// the FFT convolution is circular, but the padded grid is at least as large as the
+	// kernel reaches from any pixel within the grid, so no values wrap around its edges
+	grid.fftPad = maxRadius
+	fftWidth := util.NextPowerOf2(grid.Width + 2*maxRadius)
+	fftHeight := util.NextPowerOf2(grid.Height + 2*maxRadius)
+	grid.fftGrid = util.Make2DGridComplex128(fftWidth, fftHeight)
+	grid.fftProduct = util.Make2DGridComplex128(fftWidth, fftHeight)
+
+	// the spectrum of a mask of the pixels which are sampled, for counting them
+	// with the clip boundary those are only the pixels within the grid's bounds
+	maskX0, maskY0, maskX1, maskY1 := 0, 0, grid.Width+2*maxRadius, grid.Height+2*maxRadius
+	if grid.boundary == util.BoundaryClip {
+		maskX0, maskY0, maskX1, maskY1 = maxRadius, maxRadius, grid.Width+maxRadius, grid.Height+maxRadius
+	}
+	for y := maskY0; y < maskY1; y++ {
+		for x := maskX0; x < maskX1; x++ {
+			grid.fftGrid[y][x] = 1
+		}
+	}
+	util.FFT2D(grid.fftGrid, false, grid.Workers)
+
+	type kernelKey struct {
+		shape  string
+		radius int
+	}
+	kernels := map[kernelKey]*fftKernel{}
+	kernelFor := func(shape string, radius int) *fftKernel {
+		key := kernelKey{shape, radius}
+		if kernel, ok := kernels[key]; ok {
+			return kernel
+		}
+		kernel := grid.makeFFTKernel(shape, radius)
+		kernels[key] = kernel
+		grid.fftKernels = append(grid.fftKernels, kernel)
+		return kernel
+	}
+	for k := range grid.scales {
+		scale := &grid.scales[k]
+		if scale.usesFFT(scale.ActivatorRadius) {
+			grid.activatorFFTs[k] = kernelFor(scale.Kernel, scale.ActivatorRadius)
+		}
+		if scale.usesFFT(scale.InhibitorRadius) {
+			grid.inhibitorFFTs[k] = kernelFor(scale.Kernel, scale.InhibitorRadius)
+		}
+	}
+}
+
+// makeFFTKernel precompute the spectrum and sampled pixel counts of the given kernel
+// NB: expects grid.fftGrid to hold the spectrum of the mask of sampled pixels
+func (grid *tsGrid) makeFFTKernel(shape string, radius int) *fftKernel {
+	fftHeight, fftWidth := len(grid.fftGrid), len(grid.fftGrid[0])
+
+	// the kernel is centred on (0, 0), wrapping around to the far edges
+	for y := range grid.fftProduct {
+		for x := range grid.fftProduct[y] {
+			grid.fftProduct[y][x] = 0
+		}
+	}
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			if shape == kernelSquare || util.PointIsWithinCircle(dx, dy, 0, 0, radius) {
+				grid.fftProduct[(dy+fftHeight)%fftHeight][(dx+fftWidth)%fftWidth] = 1
+			}
+		}
+	}
+	util.FFT2D(grid.fftProduct, false, grid.Workers)
+
+	kernel := &fftKernel{
+		spectrum: util.Make2DGridFloat64(fftWidth, fftHeight),
+		counts:   util.Make2DGridFloat64(grid.Width, grid.Height),
+		averages: util.Make2DGridFloat64(grid.Width, grid.Height),
+	}
+	for y := range grid.fftProduct {
+		for x := range grid.fftProduct[y] {
+			kernel.spectrum[y][x] = real(grid.fftProduct[y][x])
+			grid.fftProduct[y][x] *= grid.fftGrid[y][x]
+		}
+	}
+
+	// convolving the mask of sampled pixels with the kernel counts them
+	util.FFT2D(grid.fftProduct, true, grid.Workers)
+	for y := 0; y < grid.Height; y++ {
+		for x := 0; x < grid.Width; x++ {
+			kernel.counts[y][x] = float64(util.Round(real(grid.fftProduct[y+grid.fftPad][x+grid.fftPad])))
+		}
+	}
+	return kernel
+}
+
+// calcFFTAverages convolve the current grid with every FFT kernel
+func (grid *tsGrid) calcFFTAverages() {
+	if len(grid.fftKernels) == 0 {
+		return
+	}
+
+	for y := range grid.fftGrid {
+		for x := range grid.fftGrid[y] {
+			grid.fftGrid[y][x] = 0
+		}
+	}
+	padded := util.PadGrid(grid.grid, grid.fftPad, grid.boundary, grid.boundaryValue)
+	for y := range padded {
+		for x := range padded[y] {
+			grid.fftGrid[y][x] = complex(padded[y][x], 0)
+		}
+	}
+	util.FFT2D(grid.fftGrid, false, grid.Workers)
+
+	for _, kernel := range grid.fftKernels {
+		for y := range grid.fftProduct {
+			for x := range grid.fftProduct[y] {
+				grid.fftProduct[y][x] = grid.fftGrid[y][x] * complex(kernel.spectrum[y][x], 0)
+			}
+		}
+		util.FFT2D(grid.fftProduct, true, grid.Workers)
+		for y := 0; y < grid.Height; y++ {
+			for x := 0; x < grid.Width; x++ {
+				kernel.averages[y][x] = real(grid.fftProduct[y+grid.fftPad][x+grid.fftPad]) / kernel.counts[y][x]
+			}
+		}
+	}
+}
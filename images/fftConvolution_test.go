@@ -0,0 +1,56 @@
+package images
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/dhodges/turing_patterns/util"
+)
+
+func TestFFTAgreesWithDirectConvolution(t *testing.T) {
+	for _, boundary := range []string{util.BoundaryClip, util.BoundaryWrap, util.BoundaryMirror, util.BoundaryConstant} {
+		for _, kernel := range []string{kernelCircle, kernelSquare} {
+			testFFTAgreesWithDirectConvolution(t, boundary, kernel)
+		}
+	}
+}
+
+func testFFTAgreesWithDirectConvolution(t *testing.T, boundary, kernel string) {
+	scales := []turingScale{
+		turingScale{ActivatorRadius: 3, InhibitorRadius: 9, Weight: 1, Symmetry: 1, Kernel: kernel, Convolution: convolutionDirect},
+		turingScale{ActivatorRadius: 3, InhibitorRadius: 9, Weight: 1, Symmetry: 1, Kernel: kernel, Convolution: convolutionFFT},
+	}
+	cfg := TSGridConfig{Width: 45, Height: 30, Scales: scales, Boundary: boundary, BoundaryValue: 0.5}
+	grid := makeTuringScaleGrid(cfg, rand.New(rand.NewSource(7)))
+	grid.calcPrefixSums()
+	grid.calcFFTAverages()
+	grid.inBands(grid.calcActivatorsAndInhibitors)
+
+	for y := 0; y < grid.Height; y++ {
+		for x := 0; x < grid.Width; x++ {
+			direct, fft := grid.activators[y][x][0], grid.activators[y][x][1]
+			if math.Abs(direct-fft) > 1e-9 {
+				t.Fatalf("%s %s activator at (%d, %d) is %v by FFT, but it should be %v", boundary, kernel, x, y, fft, direct)
+			}
+			direct, fft = grid.inhibitors[y][x][0], grid.inhibitors[y][x][1]
+			if math.Abs(direct-fft) > 1e-9 {
+				t.Fatalf("%s %s inhibitor at (%d, %d) is %v by FFT, but it should be %v", boundary, kernel, x, y, fft, direct)
+			}
+		}
+	}
+}
+
+func TestConvolutionIsChosenByRadius(t *testing.T) {
+	scale := turingScale{ActivatorRadius: 5, InhibitorRadius: 100}
+	if scale.usesFFT(scale.ActivatorRadius) {
+		t.Errorf("radius %d should be convolved directly by default", scale.ActivatorRadius)
+	}
+	if !scale.usesFFT(scale.InhibitorRadius) {
+		t.Errorf("radius %d should be convolved with an FFT by default", scale.InhibitorRadius)
+	}
+	scale.Convolution = convolutionDirect
+	if scale.usesFFT(scale.InhibitorRadius) {
+		t.Errorf("radius %d should be convolved directly when forced", scale.InhibitorRadius)
+	}
+}
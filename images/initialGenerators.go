@@ -0,0 +1,311 @@
+package images
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/dhodges/turing_patterns/util"
+)
+
+// generator fill the given grid, indexed [y][x], with initial values in [-1, +1]
+type generator func(values [][]float64, rng *rand.Rand)
+
+// generators make each named generator from its JSON params, see InitialState
+var generators = map[string]func(params json.RawMessage) (generator, error){
+	"noise":        makeUniformNoise,
+	"value":        makeCoherentNoise((*util.Noise).Value),
+	"perlin":       makeCoherentNoise((*util.Noise).Perlin),
+	"simplex":      makeCoherentNoise((*util.Noise).Simplex),
+	"radial":       makeRadialGradient,
+	"linear":       makeLinearGradient,
+	"checkerboard": makeCheckerboard,
+	"rings":        makeRings,
+	"dots":         makeDots,
+	"seed":         makeSeed,
+}
+
+// generatorNames the names of all generators, in alphabetical order
+func generatorNames() []string {
+	names := make([]string, 0, len(generators))
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// makeGenerator make the named generator from its JSON params
+func makeGenerator(name string, params json.RawMessage) (generator, error) {
+	makeGen, ok := generators[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown generator %q, expected one of %v", name, generatorNames())
+	}
+	gen, err := makeGen(params)
+	if err != nil {
+		return nil, fmt.Errorf("generator %q: %v", name, err)
+	}
+	return gen, nil
+}
+
+// decodeParams decode the given JSON params into the given defaults, rejecting unknown fields
+func decodeParams(params json.RawMessage, defaults interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(params))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(defaults)
+}
+
+// centre params shared by the generators which are centred on a point of the grid
+type centre struct {
+	CenterX float64 // fraction of the width, 0.5 is the middle
+	CenterY float64 // fraction of the height, 0.5 is the middle
+}
+
+func (c centre) distance(values [][]float64, x, y int) float64 {
+	height, width := len(values), len(values[0])
+	return math.Hypot(float64(x)-c.CenterX*float64(width-1), float64(y)-c.CenterY*float64(height-1))
+}
+
+// makeUniformNoise uniform random values, the default
+func makeUniformNoise(params json.RawMessage) (generator, error) {
+	if err := decodeParams(params, &struct{}{}); err != nil {
+		return nil, err
+	}
+	return func(values [][]float64, rng *rand.Rand) {
+		for y := range values {
+			for x := range values[y] {
+				values[y][x] = util.RandFloat64(rng, -1.0, 1.0)
+			}
+		}
+	}, nil
+}
+
+// makeCoherentNoise fractal noise from the given noise function
+// params: Frequency, the number of noise features across the larger of width and height
+// Octaves, the number of layers of noise, each at double the frequency of the last
+// and Persistence, the amplitude of each octave relative to the last
+func makeCoherentNoise(noiseFn func(*util.Noise, float64, float64) float64) func(json.RawMessage) (generator, error) {
+	return func(params json.RawMessage) (generator, error) {
+		p := struct {
+			Frequency   float64
+			Octaves     int
+			Persistence float64
+		}{Frequency: 8, Octaves: 1, Persistence: 0.5}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Frequency <= 0 || p.Octaves < 1 {
+			return nil, fmt.Errorf("Frequency must be > 0 and Octaves >= 1")
+		}
+
+		return func(values [][]float64, rng *rand.Rand) {
+			noise := util.NewNoise(rng)
+			size := len(values[0])
+			if len(values) > size {
+				size = len(values)
+			}
+			scale := p.Frequency / float64(size)
+			for y := range values {
+				for x := range values[y] {
+					value, amplitude, frequency := 0.0, 1.0, scale
+					for octave := 0; octave < p.Octaves; octave++ {
+						value += amplitude * noiseFn(noise, float64(x)*frequency, float64(y)*frequency)
+						amplitude *= p.Persistence
+						frequency *= 2
+					}
+					values[y][x] = value
+				}
+			}
+			stretchToRange(values)
+		}, nil
+	}
+}
+
+// makeRadialGradient +1 at the centre, falling to -1 at the given radius and beyond
+// params: CenterX, CenterY and Radius, a fraction of the smaller of width and height
+func makeRadialGradient(params json.RawMessage) (generator, error) {
+	p := struct {
+		centre
+		Radius float64
+	}{centre{0.5, 0.5}, 0.5}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Radius <= 0 {
+		return nil, fmt.Errorf("Radius must be > 0")
+	}
+
+	return func(values [][]float64, rng *rand.Rand) {
+		size := len(values[0])
+		if len(values) < size {
+			size = len(values)
+		}
+		radius := p.Radius * float64(size)
+		for y := range values {
+			for x := range values[y] {
+				values[y][x] = util.Constrain(-1.0, 1-2*p.distance(values, x, y)/radius, 1.0)
+			}
+		}
+	}, nil
+}
+
+// makeLinearGradient -1 on one side of the grid rising to +1 on the opposite side
+// params: Angle, the direction of the rise in degrees, 0 is left to right
+func makeLinearGradient(params json.RawMessage) (generator, error) {
+	p := struct {
+		Angle float64
+	}{}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	return func(values [][]float64, rng *rand.Rand) {
+		angle := p.Angle * math.Pi / 180
+		dx, dy := math.Cos(angle), math.Sin(angle)
+		for y := range values {
+			for x := range values[y] {
+				values[y][x] = float64(x)*dx + float64(y)*dy
+			}
+		}
+		stretchToRange(values)
+	}, nil
+}
+
+// makeCheckerboard alternating squares of -1 and +1
+// params: Size, the width of each square in pixels
+func makeCheckerboard(params json.RawMessage) (generator, error) {
+	p := struct {
+		Size int
+	}{32}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Size < 1 {
+		return nil, fmt.Errorf("Size must be >= 1")
+	}
+
+	return func(values [][]float64, rng *rand.Rand) {
+		for y := range values {
+			for x := range values[y] {
+				if (x/p.Size+y/p.Size)%2 == 0 {
+					values[y][x] = -1.0
+				} else {
+					values[y][x] = 1.0
+				}
+			}
+		}
+	}, nil
+}
+
+// makeRings concentric rings, a cosine wave of the distance from the centre
+// params: CenterX, CenterY and Wavelength, the distance between rings in pixels
+func makeRings(params json.RawMessage) (generator, error) {
+	p := struct {
+		centre
+		Wavelength float64
+	}{centre{0.5, 0.5}, 32}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Wavelength <= 0 {
+		return nil, fmt.Errorf("Wavelength must be > 0")
+	}
+
+	return func(values [][]float64, rng *rand.Rand) {
+		for y := range values {
+			for x := range values[y] {
+				values[y][x] = math.Cos(2 * math.Pi * p.distance(values, x, y) / p.Wavelength)
+			}
+		}
+	}, nil
+}
+
+// makeDots sparse dots of +1, at random positions, on a background of -1
+// params: Density, the fraction of pixels at the centre of a dot, and Radius, of each dot in pixels
+func makeDots(params json.RawMessage) (generator, error) {
+	p := struct {
+		Density float64
+		Radius  int
+	}{0.001, 2}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Density < 0 || p.Density > 1 || p.Radius < 0 {
+		return nil, fmt.Errorf("Density must be between 0 and 1, and Radius >= 0")
+	}
+
+	return func(values [][]float64, rng *rand.Rand) {
+		height, width := len(values), len(values[0])
+		fillGrid(values, -1.0)
+		dots := util.Round(p.Density * float64(width*height))
+		for i := 0; i < dots; i++ {
+			drawDisc(values, rng.Intn(width), rng.Intn(height), p.Radius)
+		}
+	}, nil
+}
+
+// makeSeed a single disc of +1, centred on a background of -1
+// params: Radius, of the disc in pixels
+func makeSeed(params json.RawMessage) (generator, error) {
+	p := struct {
+		Radius int
+	}{8}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Radius < 0 {
+		return nil, fmt.Errorf("Radius must be >= 0")
+	}
+
+	return func(values [][]float64, rng *rand.Rand) {
+		height, width := len(values), len(values[0])
+		fillGrid(values, -1.0)
+		drawDisc(values, width/2, height/2, p.Radius)
+	}, nil
+}
+
+func fillGrid(values [][]float64, value float64) {
+	for y := range values {
+		for x := range values[y] {
+			values[y][x] = value
+		}
+	}
+}
+
+// drawDisc set every value within the given circle to +1
+func drawDisc(values [][]float64, cx, cy, radius int) {
+	height, width := len(values), len(values[0])
+	for y := util.ConstrainInt(0, cy-radius, height); y < util.ConstrainInt(0, cy+radius+1, height); y++ {
+		for x := util.ConstrainInt(0, cx-radius, width); x < util.ConstrainInt(0, cx+radius+1, width); x++ {
+			if util.PointIsWithinCircle(x, y, cx, cy, radius) {
+				values[y][x] = 1.0
+			}
+		}
+	}
+}
+
+// stretchToRange linearly rescale the given values to span [-1, +1]
+func stretchToRange(values [][]float64) {
+	smallest, largest := values[0][0], values[0][0]
+	for y := range values {
+		for x := range values[y] {
+			smallest = math.Min(smallest, values[y][x])
+			largest = math.Max(largest, values[y][x])
+		}
+	}
+	if largest == smallest {
+		fillGrid(values, 0.0)
+		return
+	}
+	for y := range values {
+		for x := range values[y] {
+			values[y][x] = (values[y][x]-smallest)/(largest-smallest)*2 - 1
+		}
+	}
+}
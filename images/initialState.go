@@ -0,0 +1,115 @@
+package images
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/dhodges/turing_patterns/util"
+)
+
+// InitialState how the values of a grid begin, before the first iteration
+// the zero value leaves the grid as uniform random noise
+type InitialState struct {
+	Generator string          // the name of a procedural generator of the initial values, see generators
+	Params    json.RawMessage // the generator's own parameters, as a JSON object
+
+	Image  string  // a PNG, JPEG or GIF file whose luminance gives the initial values, instead of a Generator
+	Resize string  // how the image is fitted to the grid, resizeStretch (default), resizeFit, resizeFill or resizeNone
+	Invert bool    // negate the initial values, e.g. map light pixels to -1 and dark pixels to +1
+	Noise  float64 // the fraction of the grid's random noise mixed back in, from 0 (none) to 1 (only noise)
+}
+
+// how an initial image is fitted to a grid
+const (
+	resizeStretch = "stretch" // scale to the grid's width and height, ignoring the aspect ratio
+	resizeFit     = "fit"     // scale to fit within the grid, keeping the aspect ratio; the margins are 0
+	resizeFill    = "fill"    // scale to cover the grid, keeping the aspect ratio; the overflow is cropped
+	resizeNone    = "none"    // keep the original size, centred; cropped or with margins of 0
+)
+
+// initialValues return the initial values of a grid of width x height, or nil to leave its random values
+func (init InitialState) initialValues(width, height int, rng *rand.Rand) ([][]float64, error) {
+	var values [][]float64
+	switch {
+	case init.Image != "":
+		img, err := util.LoadImage(init.Image)
+		if err != nil {
+			return nil, err
+		}
+		if values, err = fitToGrid(util.LuminanceGrid(img), init.Resize, width, height); err != nil {
+			return nil, fmt.Errorf("%s: %v", init.Image, err)
+		}
+	case init.Generator != "":
+		gen, err := makeGenerator(init.Generator, init.Params)
+		if err != nil {
+			return nil, err
+		}
+		values = util.Make2DGridFloat64(width, height)
+		gen(values, rng)
+	default:
+		return nil, nil
+	}
+
+	if init.Invert {
+		for y := range values {
+			for x := range values[y] {
+				values[y][x] = -values[y][x]
+			}
+		}
+	}
+	return values, nil
+}
+
+// applyInitialState replace the random values of the given grid with the given initial state
+// any random values needed by its generator are drawn from the given random number generator
+func applyInitialState(grid *tsGrid, init InitialState, rng *rand.Rand) error {
+	values, err := init.initialValues(grid.Width, grid.Height, rng)
+	if err != nil || values == nil {
+		return err
+	}
+
+	noise := util.Constrain(0.0, init.Noise, 1.0)
+	for y := 0; y < grid.Height; y++ {
+		for x := 0; x < grid.Width; x++ {
+			grid.grid[y][x] = (1-noise)*values[y][x] + noise*grid.grid[y][x]
+		}
+	}
+	return nil
+}
+
+// fitToGrid resize the given luminance grid to width x height, mapping its values from [0, 1] to [-1, +1]
+func fitToGrid(luminance [][]float64, resize string, width, height int) ([][]float64, error) {
+	srcWidth, srcHeight := float64(len(luminance[0])), float64(len(luminance))
+
+	scaledWidth, scaledHeight := width, height
+	switch resize {
+	case resizeStretch, "":
+	case resizeFit:
+		scale := math.Min(float64(width)/srcWidth, float64(height)/srcHeight)
+		scaledWidth, scaledHeight = util.Round(srcWidth*scale), util.Round(srcHeight*scale)
+	case resizeFill:
+		scale := math.Max(float64(width)/srcWidth, float64(height)/srcHeight)
+		scaledWidth, scaledHeight = util.Round(srcWidth*scale), util.Round(srcHeight*scale)
+	case resizeNone:
+		scaledWidth, scaledHeight = len(luminance[0]), len(luminance)
+	default:
+		return nil, fmt.Errorf("unknown resize %q, expected %q, %q, %q or %q", resize, resizeStretch, resizeFit, resizeFill, resizeNone)
+	}
+	scaledWidth, scaledHeight = util.ConstrainInt(1, scaledWidth, math.MaxInt32), util.ConstrainInt(1, scaledHeight, math.MaxInt32)
+	scaled := util.ResampleGrid(luminance, scaledWidth, scaledHeight)
+
+	// centre the scaled values within the grid
+	values := util.Make2DGridFloat64(width, height)
+	offsetX, offsetY := (scaledWidth-width)/2, (scaledHeight-height)/2
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			sx, sy := x+offsetX, y+offsetY
+			if 0 <= sx && sx < scaledWidth && 0 <= sy && sy < scaledHeight {
+				values[y][x] = scaled[sy][sx]*2 - 1
+			}
+		}
+	}
+	return values, nil
+}
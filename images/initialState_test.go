@@ -0,0 +1,99 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestImage write a 4x2 PNG, black on the left and white on the right
+func writeTestImage(t *testing.T) string {
+	img := image.NewGray(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		img.SetGray(2, y, color.Gray{255})
+		img.SetGray(3, y, color.Gray{255})
+	}
+	filename := filepath.Join(t.TempDir(), "initial.png")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestInitialStateFromImage(t *testing.T) {
+	filename := writeTestImage(t)
+	tests := []struct {
+		init                InitialState
+		width, height       int
+		x, y                int
+		expected, tolerance float64
+	}{
+		{InitialState{Image: filename}, 8, 4, 0, 0, -1.0, 0},
+		{InitialState{Image: filename}, 8, 4, 7, 3, 1.0, 0},
+		{InitialState{Image: filename, Invert: true}, 8, 4, 7, 3, -1.0, 0},
+		{InitialState{Image: filename, Resize: resizeFit}, 8, 8, 0, 0, 0.0, 0},
+		{InitialState{Image: filename, Resize: resizeFit}, 8, 8, 0, 4, -1.0, 0},
+		{InitialState{Image: filename, Resize: resizeFill}, 4, 4, 0, 0, -1.0, 0},
+		{InitialState{Image: filename, Resize: resizeNone}, 6, 4, 0, 0, 0.0, 0},
+		{InitialState{Image: filename, Resize: resizeNone}, 6, 4, 1, 1, -1.0, 0},
+		{InitialState{Image: filename, Noise: 0.5}, 8, 4, 7, 3, 0.5, 0.5},
+	}
+	for _, test := range tests {
+		grid := makeTuringScaleGrid(TSGridConfig{Width: test.width, Height: test.height, Scales: testTuringScales}, rand.New(rand.NewSource(1)))
+		if err := applyInitialState(grid, test.init, rand.New(rand.NewSource(2))); err != nil {
+			t.Fatal(err)
+		}
+		if value := grid.grid[test.y][test.x]; math.Abs(value-test.expected) > test.tolerance {
+			t.Errorf("%+v at (%d, %d) of %dx%d is %v, but it should be %v", test.init, test.x, test.y, test.width, test.height, value, test.expected)
+		}
+	}
+}
+
+func TestInitialStateGenerators(t *testing.T) {
+	for _, name := range generatorNames() {
+		init := InitialState{Generator: name}
+		values, err := init.initialValues(40, 30, rand.New(rand.NewSource(3)))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(values) != 30 || len(values[0]) != 40 {
+			t.Fatalf("%s made a %dx%d grid, but it should be 40x30", name, len(values[0]), len(values))
+		}
+		smallest, largest := math.Inf(1), math.Inf(-1)
+		for y := range values {
+			for x := range values[y] {
+				smallest = math.Min(smallest, values[y][x])
+				largest = math.Max(largest, values[y][x])
+			}
+		}
+		if smallest < -1.0 || largest > 1.0 || smallest == largest {
+			t.Errorf("%s values span [%v, %v], but they should vary within [-1, +1]", name, smallest, largest)
+		}
+	}
+
+	init := InitialState{Generator: "seed", Params: []byte(`{"Radius": 3}`)}
+	values, _ := init.initialValues(20, 20, nil)
+	if values[10][10] != 1.0 || values[10][14] != -1.0 {
+		t.Errorf("a seed of radius 3 should cover (10, 10) but not (14, 10)")
+	}
+
+	for _, bad := range []InitialState{
+		{Generator: "plaid"},
+		{Generator: "perlin", Params: []byte(`{"Frequency": -1}`)},
+		{Generator: "rings", Params: []byte(`{"Wavelenght": 10}`)},
+	} {
+		if _, err := bad.initialValues(10, 10, rand.New(rand.NewSource(1))); err == nil {
+			t.Errorf("%s with params %s should be rejected", bad.Generator, bad.Params)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package images
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/dhodges/turing_patterns/util"
+)
+
+// Metadata how an image was generated, embedded in the text chunks of each PNG file so that it can be reproduced
+type Metadata struct {
+	Software  string // the name and version of the program which generated the image
+	Model     string // ModelGray, ModelRGB or ModelRGB3
+	Seed      int64
+	Iteration int
+	Config    string // the full JSON config of the run
+}
+
+// keywords of the PNG text chunks holding each field of Metadata
+const (
+	keywordSoftware  = "Software"
+	keywordModel     = "Model"
+	keywordSeed      = "Seed"
+	keywordIteration = "Iteration"
+	keywordConfig    = "Config"
+)
+
+// Text the PNG text chunks holding this metadata
+func (meta Metadata) Text() map[string]string {
+	return map[string]string{
+		keywordSoftware:  meta.Software,
+		keywordModel:     meta.Model,
+		keywordSeed:      strconv.FormatInt(meta.Seed, 10),
+		keywordIteration: strconv.Itoa(meta.Iteration),
+		keywordConfig:    meta.Config,
+	}
+}
+
+// metadataFromText return the metadata held in the given PNG text chunks
+func metadataFromText(text map[string]string) (Metadata, error) {
+	for _, keyword := range []string{keywordModel, keywordSeed, keywordIteration, keywordConfig} {
+		if _, ok := text[keyword]; !ok {
+			return Metadata{}, fmt.Errorf("no %s in the image metadata", keyword)
+		}
+	}
+
+	seed, err := strconv.ParseInt(text[keywordSeed], 10, 64)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("invalid %s in the image metadata: %v", keywordSeed, err)
+	}
+	iteration, err := strconv.Atoi(text[keywordIteration])
+	if err != nil {
+		return Metadata{}, fmt.Errorf("invalid %s in the image metadata: %v", keywordIteration, err)
+	}
+
+	return Metadata{
+		Software:  text[keywordSoftware],
+		Model:     text[keywordModel],
+		Seed:      seed,
+		Iteration: iteration,
+		Config:    text[keywordConfig],
+	}, nil
+}
+
+// ReadMetadata return the metadata embedded in the given PNG file
+func ReadMetadata(filename string) (Metadata, error) {
+	text, err := util.ReadPNGText(filename)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("%s: %v", filename, err)
+	}
+	meta, err := metadataFromText(text)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("%s: %v", filename, err)
+	}
+	return meta, nil
+}
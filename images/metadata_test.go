@@ -0,0 +1,36 @@
+package images
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMetadataRoundTrip(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "image.png")
+	img := MakeTSImageRGB(8, 6, 42)
+	meta := Metadata{
+		Software:  "turing_patterns 1.0",
+		Model:     ModelRGB,
+		Seed:      img.Seed(),
+		Iteration: 12,
+		Config:    `{"Width": 8, "Height": 6}`,
+	}
+	if err := img.OutputPNG(filename, meta); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadMetadata(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != meta {
+		t.Errorf("read metadata %+v, but expected %+v", read, meta)
+	}
+}
+
+func TestMetadataIsRequired(t *testing.T) {
+	_, err := metadataFromText(map[string]string{keywordModel: ModelGray, keywordSeed: "1"})
+	if err == nil {
+		t.Errorf("metadata without an iteration or config should be rejected")
+	}
+}
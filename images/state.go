@@ -0,0 +1,280 @@
+package images
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+
+	"github.com/dhodges/turing_patterns/hsb"
+	"github.com/dhodges/turing_patterns/util"
+)
+
+// the color models of the images which can be saved and restored
+const (
+	ModelGray = "gray"
+	ModelRGB  = "rgb"
+	ModelRGB3 = "rgb3"
+)
+
+// the binary state format written by SaveState, all little endian, in order:
+//
+//	magic "TSPS" and version uint32
+//	model, a length-prefixed string
+//	iteration uint64
+//	seed int64, then the number of random values drawn since seeding uint64
+//	the grid config, a length-prefixed JSON TSGridConfig
+//	the grid values, height*width float64, row by row
+//	the colors, height*width*4 float64 (H, S, B, A) row by row, only for the rgb model
+//	the green then blue channels, each as seed int64, draws uint64 and height*width float64, only for the rgb3 model
+//
+// version 1 is the same, except that it has no rgb3 model
+const stateVersion = 2
+
+var stateMagic = [4]byte{'T', 'S', 'P', 'S'}
+
+// imageState everything needed to continue a simulation exactly where it stopped
+type imageState struct {
+	Model     string
+	Iteration uint64
+	Seed      int64
+	Draws     uint64
+	Config    TSGridConfig
+	Grid      [][]float64
+	Colors    [][]hsb.NHSBA
+	Channels  []channelState
+}
+
+// channelState the state of one additional channel, whose grid has its own random number generator
+type channelState struct {
+	Seed  int64
+	Draws uint64
+	Grid  [][]float64
+}
+
+// rgb3Channels the number of channels saved after the grid by the rgb3 model
+const rgb3Channels = 2
+
+// writeStateFile write the given state to the given file
+// NB: the file is written atomically, so an interrupted write never replaces the previous state with a partial one
+func writeStateFile(filename string, state *imageState) error {
+	return util.WriteFileAtomically(filename, func(w io.Writer) error {
+		return writeState(w, state)
+	})
+}
+
+// readStateFile read the state from the given file
+func readStateFile(filename string) (*imageState, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	state, err := readState(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return state, nil
+}
+
+func writeState(w io.Writer, state *imageState) error {
+	config, err := json.Marshal(state.Config)
+	if err != nil {
+		return err
+	}
+
+	header := []interface{}{stateMagic, uint32(stateVersion)}
+	for _, value := range header {
+		if err := binary.Write(w, binary.LittleEndian, value); err != nil {
+			return err
+		}
+	}
+	if err := writeBytes(w, []byte(state.Model)); err != nil {
+		return err
+	}
+	for _, value := range []interface{}{state.Iteration, state.Seed, state.Draws} {
+		if err := binary.Write(w, binary.LittleEndian, value); err != nil {
+			return err
+		}
+	}
+	if err := writeBytes(w, config); err != nil {
+		return err
+	}
+
+	for _, row := range state.Grid {
+		if err := binary.Write(w, binary.LittleEndian, row); err != nil {
+			return err
+		}
+	}
+	for _, row := range state.Colors {
+		for _, c := range row {
+			if err := binary.Write(w, binary.LittleEndian, [4]float64{c.H, c.S, c.B, c.A}); err != nil {
+				return err
+			}
+		}
+	}
+	for _, ch := range state.Channels {
+		for _, value := range []interface{}{ch.Seed, ch.Draws} {
+			if err := binary.Write(w, binary.LittleEndian, value); err != nil {
+				return err
+			}
+		}
+		for _, row := range ch.Grid {
+			if err := binary.Write(w, binary.LittleEndian, row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func readState(r io.Reader) (*imageState, error) {
+	state, err := readStateHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range []interface{}{&state.Iteration, &state.Seed, &state.Draws} {
+		if err := binary.Read(r, binary.LittleEndian, value); err != nil {
+			return nil, err
+		}
+	}
+	config, err := readBytes(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(config, &state.Config); err != nil {
+		return nil, err
+	}
+
+	width, height := state.Config.Width, state.Config.Height
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid grid size %dx%d", width, height)
+	}
+	state.Grid = util.Make2DGridFloat64(width, height)
+	for _, row := range state.Grid {
+		if err := binary.Read(r, binary.LittleEndian, row); err != nil {
+			return nil, err
+		}
+	}
+	if state.Model == ModelRGB {
+		state.Colors = util.Make2DGridNHSBA(width, height)
+		for _, row := range state.Colors {
+			for x := range row {
+				var c [4]float64
+				if err := binary.Read(r, binary.LittleEndian, &c); err != nil {
+					return nil, err
+				}
+				row[x] = hsb.NHSBA{H: c[0], S: c[1], B: c[2], A: c[3]}
+			}
+		}
+	}
+	if state.Model == ModelRGB3 {
+		state.Channels = make([]channelState, rgb3Channels)
+		for i := range state.Channels {
+			ch := &state.Channels[i]
+			for _, value := range []interface{}{&ch.Seed, &ch.Draws} {
+				if err := binary.Read(r, binary.LittleEndian, value); err != nil {
+					return nil, err
+				}
+			}
+			ch.Grid = util.Make2DGridFloat64(width, height)
+			for _, row := range ch.Grid {
+				if err := binary.Read(r, binary.LittleEndian, row); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return state, nil
+}
+
+// readStateHeader read the magic, version and model which begin every state
+func readStateHeader(r io.Reader) (*imageState, error) {
+	var magic [4]byte
+	var version uint32
+	if err := binary.Read(r, binary.LittleEndian, &magic); err != nil {
+		return nil, err
+	}
+	if magic != stateMagic {
+		return nil, errors.New("not a turing patterns state file")
+	}
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return nil, err
+	}
+	if version < 1 || version > stateVersion {
+		return nil, fmt.Errorf("unsupported state version %d, expected at most %d", version, stateVersion)
+	}
+
+	model, err := readBytes(r)
+	if err != nil {
+		return nil, err
+	}
+	if version < 2 && string(model) == ModelRGB3 {
+		return nil, fmt.Errorf("unsupported state version %d for the %s model, expected at least 2", version, ModelRGB3)
+	}
+	return &imageState{Model: string(model)}, nil
+}
+
+// writeBytes write the given bytes prefixed by their length
+func writeBytes(w io.Writer, b []byte) error {
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(b))); err != nil {
+		return err
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// readBytes read bytes prefixed by their length
+func readBytes(r io.Reader) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return nil, err
+	}
+	b := make([]byte, length)
+	_, err := io.ReadFull(r, b)
+	return b, err
+}
+
+// StateModel return the color model of the image saved in the given state file
+func StateModel(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	state, err := readStateHeader(bufio.NewReader(f))
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", filename, err)
+	}
+	return state.Model, nil
+}
+
+// gridState the state of the given grid and random number generator, for the given model and iteration
+func gridState(model string, iteration int, grid *tsGrid, source *util.RandSource) *imageState {
+	config := grid.config()
+	config.Seed = source.InitialSeed()
+	return &imageState{
+		Model:     model,
+		Iteration: uint64(iteration),
+		Seed:      source.InitialSeed(),
+		Draws:     source.Draws(),
+		Config:    config,
+		Grid:      grid.copyOfCurrentState(),
+	}
+}
+
+// restoreGrid restore the grid and random number generator saved in the given state
+func restoreGrid(state *imageState) (*tsGrid, *rand.Rand, *util.RandSource) {
+	grid := makeTuringScaleGrid(state.Config, nil)
+	grid.grid = state.Grid
+
+	rng, source := util.NewRand(state.Seed)
+	source.Skip(state.Draws)
+	return grid, rng, source
+}
@@ -0,0 +1,91 @@
+package images
+
+import (
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestResumeContinuesExactly(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "state.bin")
+
+	img := MakeTSImageRGB(24, 18, 5)
+	img.grid = makeTuringScaleGrid(TSGridConfig{Width: 24, Height: 18, Scales: testTuringScales, Boundary: "wrap"}, img.rng)
+	img.NextIteration(context.Background())
+	if err := img.SaveState(filename, 1); err != nil {
+		t.Fatal(err)
+	}
+	img.NextIteration(context.Background())
+	img.NextIteration(context.Background())
+
+	if model, err := StateModel(filename); err != nil || model != ModelRGB {
+		t.Fatalf("state model is %q (%v), but it should be %q", model, err, ModelRGB)
+	}
+
+	resumed := MakeTSImageRGB(2, 2, 0)
+	if iteration, err := resumed.LoadState(filename); err != nil || iteration != 1 {
+		t.Errorf("resumed iteration is %d (%v), but it should be %d", iteration, err, 1)
+	}
+	if resumed.Seed() != 5 || resumed.source.Draws() != img.source.Draws() {
+		t.Errorf("resumed random source is at (%d, %d), but it should be at (%d, %d)",
+			resumed.Seed(), resumed.source.Draws(), img.Seed(), img.source.Draws())
+	}
+	resumed.NextIteration(context.Background())
+	resumed.NextIteration(context.Background())
+
+	if resumed.grid.boundary != "wrap" || len(resumed.grid.scales) != len(testTuringScales) {
+		t.Fatalf("resumed grid config is %+v, but it should be %+v", resumed.grid.config(), img.grid.config())
+	}
+	for y := 0; y < img.grid.Height; y++ {
+		for x := 0; x < img.grid.Width; x++ {
+			if resumed.grid.grid[y][x] != img.grid.grid[y][x] {
+				t.Fatalf("resumed grid[%d][%d] is %v, but it should be %v", y, x, resumed.grid.grid[y][x], img.grid.grid[y][x])
+			}
+			if resumed.colors[y][x] != img.colors[y][x] {
+				t.Fatalf("resumed colors[%d][%d] is %v, but it should be %v", y, x, resumed.colors[y][x], img.colors[y][x])
+			}
+		}
+	}
+}
+
+func TestStateVersions(t *testing.T) {
+	dir := t.TempDir()
+	for _, test := range []struct {
+		model   string
+		version uint32
+		valid   bool
+	}{
+		{ModelGray, stateVersion, true},
+		{ModelGray, 1, true},
+		{ModelRGB3, 1, false},
+		{ModelGray, stateVersion + 1, false},
+		{ModelGray, 0, false},
+	} {
+		filename := filepath.Join(dir, test.model+".tsps")
+		var err error
+		if test.model == ModelRGB3 {
+			err = MakeTSImageRGB3(8, 6, 3).SaveState(filename, 1)
+		} else {
+			err = MakeTSImageGray(8, 6, 3).SaveState(filename, 1)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the version follows the 4 byte magic
+		contents, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		binary.LittleEndian.PutUint32(contents[4:8], test.version)
+		if err := ioutil.WriteFile(filename, contents, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := readStateFile(filename); (err == nil) != test.valid {
+			t.Errorf("a %s state of version %d: error %v, but it should be valid: %v", test.model, test.version, err, test.valid)
+		}
+	}
+}
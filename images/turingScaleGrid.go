@@ -1,7 +1,12 @@
 package images
 
 import (
+	"context"
+	"encoding/json"
 	"math"
+	"math/rand"
+	"runtime"
+	"time"
 
 	"github.com/dhodges/turing_patterns/util"
 )
@@ -9,16 +14,61 @@ import (
 // tsGrid a grid of values which change with each iteration using turing scale variations
 // see: https://softologyblog.wordpress.com/2011/07/05/multi-scale-turing-patterns/
 // and: http://www.jonathanmccabe.com/Cyclic_Symmetric_Multi-Scale_Turing_Patterns.pdf
+//
+// all grids are indexed [y][x], i.e. by row then column
 type tsGrid struct {
-	Width      int
-	Height     int
-	scales     []turingScale
-	grid       [][]float64
-	activators [][][]float64
-	inhibitors [][][]float64
-	variations [][][]float64
+	Width         int
+	Height        int
+	Workers       int
+	updateMode    string
+	boundary      string
+	boundaryValue float64
+	initialState  InitialState
+	scales        []turingScale
+	grid          [][]float64
+	next          [][]float64
+	directPad     int // pixels beyond each edge included in the prefix sums, for every boundary but clip
+	rowSums       *util.RowPrefixSums
+	areaSums      *util.SummedAreaTable
+	activators    [][][]float64
+	inhibitors    [][][]float64
+	variations    [][][]float64
+	winners       [][]int // the index of the scale which changed each pixel in the latest iteration
+	change        IterationChange
+
+	// kernels convolved with an FFT, see fftConvolution.go
+	fftKernels    []*fftKernel
+	activatorFFTs []*fftKernel // per scale, nil when the activator is summed directly
+	inhibitorFFTs []*fftKernel // per scale, nil when the inhibitor is summed directly
+	fftPad        int          // pixels beyond each edge included in the FFT convolution
+	fftGrid       [][]complex128
+	fftProduct    [][]complex128
+}
+
+// TSGridConfig parameters that define the grid of a turing scale image
+type TSGridConfig struct {
+	Width         int
+	Height        int
+	Seed          int64 // the seed of the image's random number generator, or 0 to keep its current seed
+	Scales        []turingScale
+	Boundary      string  // how pixels beyond the edges are sampled: "clip" (default), "wrap", "mirror" or "constant"
+	BoundaryValue float64 // the value of every pixel beyond the edges, for the "constant" boundary
+	UpdateMode    string  // how each iteration updates the grid: "synchronous" (default) or "inplace"
+	InitialState  InitialState
+	ColorMode     string          // how an RGB image colors each pixel after each iteration, see colorModes
+	ColorParams   json.RawMessage // the color mode's own parameters, as a JSON object
+	ColorBlend    float64         `json:",omitempty"` // the Rate of the "blend" color mode, an alias kept for configs written before ColorParams
+	ColorMap      ColorMap        // how a grayscale image colors each pixel, by its value
+	Channels      []ChannelConfig // the red, green and blue grids of an rgb3 image, see TSImageRGB3
+	Coupling      float64         // the fraction of each rgb3 channel's values taken from the other channels, per iteration
 }
 
+// update modes of a tsGrid
+const (
+	updateSynchronous = "synchronous" // read from the previous grid, write to the next one
+	updateInPlace     = "inplace"     // read from, and write to, the same grid in a single sweep
+)
+
 // turingScale one of more of these are used to change a grid of values with each iteration
 type turingScale struct {
 	ActivatorRadius int
@@ -26,116 +76,423 @@ type turingScale struct {
 	SmallAmount     float64
 	Weight          float64
 	Symmetry        int
+	Kernel          string // the shape averaged for activators and inhibitors, kernelCircle (default) or kernelSquare
+	Convolution     string // how the kernel is averaged, convolutionAuto (default), convolutionFFT or convolutionDirect
+	Color           string `json:",omitempty"` // optional, in hex e.g. "#ff8000" or HSB e.g. "hsb(30, 1, 1)": pixels of an RGB image move towards it wherever this scale wins
 }
 
+// kernel shapes of a turingScale
+const (
+	kernelCircle = "circle" // exact circle, O(radius) per pixel
+	kernelSquare = "square" // square of side 2*radius+1, O(1) per pixel
+)
+
 // DefaultTuringScales default values when we have no config
 var defaultTuringScales = []turingScale{
-	turingScale{20, 40, 0.04, 1, 2},
-	turingScale{10, 20, 0.03, 1, 2},
-	turingScale{5, 10, 0.02, 1, 2},
-	turingScale{1, 2, 0.01, 1, 2},
+	turingScale{ActivatorRadius: 20, InhibitorRadius: 40, SmallAmount: 0.04, Weight: 1, Symmetry: 2},
+	turingScale{ActivatorRadius: 10, InhibitorRadius: 20, SmallAmount: 0.03, Weight: 1, Symmetry: 2},
+	turingScale{ActivatorRadius: 5, InhibitorRadius: 10, SmallAmount: 0.02, Weight: 1, Symmetry: 2},
+	turingScale{ActivatorRadius: 1, InhibitorRadius: 2, SmallAmount: 0.01, Weight: 1, Symmetry: 2},
 }
 
-// makeTuringScaleGrid create a default multi-scale turing grid from the given params
-func makeTuringScaleGrid(width, height int, scales []turingScale) *tsGrid {
-	return &tsGrid{
-		Width:      width,
-		Height:     height,
-		scales:     scales,
-		grid:       util.Make2DGridFloat64Randomised(width, height),
-		activators: util.Make3DGridFloat64(width, height, len(scales)),
-		inhibitors: util.Make3DGridFloat64(width, height, len(scales)),
-		variations: util.Make3DGridFloat64(width, height, len(scales)),
+// Scale the parameters of one turing scale, for configs built in code rather than read from JSON
+type Scale = turingScale
+
+// DefaultScales the turing scales used when none are configured
+func DefaultScales() []Scale {
+	return append([]Scale(nil), defaultTuringScales...)
+}
+
+// makeTuringScaleGrid create a multi-scale turing grid from the given config
+// its initial values are drawn from the given random number generator, or are all zero if it is nil
+// NB: the config's InitialState is not applied, see applyInitialState
+func makeTuringScaleGrid(cfg TSGridConfig, rng *rand.Rand) *tsGrid {
+	width, height := cfg.Width, cfg.Height
+	grid := &tsGrid{
+		Width:         width,
+		Height:        height,
+		Workers:       runtime.NumCPU(),
+		updateMode:    cfg.UpdateMode,
+		boundary:      cfg.Boundary,
+		boundaryValue: cfg.BoundaryValue,
+		initialState:  cfg.InitialState,
+		scales:        cfg.Scales,
+		grid:          util.Make2DGridFloat64(width, height),
+		next:          util.Make2DGridFloat64(width, height),
+		activators:    util.Make3DGridFloat64(width, height, len(cfg.Scales)),
+		inhibitors:    util.Make3DGridFloat64(width, height, len(cfg.Scales)),
+		variations:    util.Make3DGridFloat64(width, height, len(cfg.Scales)),
+		winners:       util.Make2DGridInt(width, height),
+	}
+	if rng != nil {
+		grid.grid = util.Make2DGridFloat64Randomised(rng, width, height)
+	}
+	if grid.updateMode == "" {
+		grid.updateMode = updateSynchronous
+	}
+	if grid.boundary == "" {
+		grid.boundary = util.BoundaryClip
+	}
+	grid.makeFFTKernels()
+	grid.calcDirectPad()
+	return grid
+}
+
+// newTuringScaleGrid make the grid of the given config, drawing its random values, then its initial state, from rng
+func newTuringScaleGrid(cfg TSGridConfig, rng *rand.Rand) (*tsGrid, error) {
+	grid := makeTuringScaleGrid(cfg, rng)
+	if err := applyInitialState(grid, cfg.InitialState, rng); err != nil {
+		return nil, err
+	}
+	return grid, nil
+}
+
+// seedOf the seed of the given config, or one taken from the current time if it has none
+func seedOf(cfg TSGridConfig) int64 {
+	if cfg.Seed != 0 {
+		return cfg.Seed
+	}
+	return time.Now().UnixNano()
+}
+
+// config return the config which defines this grid
+func (grid *tsGrid) config() TSGridConfig {
+	return TSGridConfig{
+		Width:         grid.Width,
+		Height:        grid.Height,
+		Scales:        grid.scales,
+		Boundary:      grid.boundary,
+		BoundaryValue: grid.boundaryValue,
+		UpdateMode:    grid.updateMode,
+		InitialState:  grid.initialState,
 	}
 }
 
 // NextIteration generate the next variation of this grid of values
-func (grid tsGrid) NextIteration() {
-	grid.calcNextVariations()
+// if the context is done before the iteration is complete, the grid is left unchanged and the context's error is returned
+func (grid *tsGrid) NextIteration(ctx context.Context) error {
+	var err error
+	if grid.updateMode == updateInPlace {
+		err = grid.calcNextVariationsInPlace(ctx)
+	} else {
+		err = grid.calcNextVariationsSynchronously(ctx)
+	}
+	if err != nil {
+		return err
+	}
 	grid.normaliseGridValues()
+	grid.calcChange()
+	return nil
 }
 
-func (grid tsGrid) sampleXY(x, y, scaleNdx int) float64 {
-	grid.activators[x][y][scaleNdx] = util.AverageOfPixelsWithinCircle(x, y, grid.scales[scaleNdx].ActivatorRadius, grid.grid)
-	grid.activators[x][y][scaleNdx] *= grid.scales[scaleNdx].Weight
-
-	grid.inhibitors[x][y][scaleNdx] = util.AverageOfPixelsWithinCircle(x, y, grid.scales[scaleNdx].InhibitorRadius, grid.grid)
-	grid.inhibitors[x][y][scaleNdx] *= grid.scales[scaleNdx].Weight
+// undoIteration restore the grid, and its change, from before the latest call of NextIteration
+// NB: each iteration leaves the previous values in the next grid, for calcChange
+func (grid *tsGrid) undoIteration(previous IterationChange) {
+	grid.grid, grid.next = grid.next, grid.grid
+	grid.change = previous
+}
 
-	// the variation can be calculated as an average of values within an arbitrary radius from x,y
-	// but instead we use a radius of one pixel, i.e. just the value at x,y
-	// apparently a radius of one pixel produces "the sharpest, most detailed images"
-	return math.Abs(grid.activators[x][y][scaleNdx] - grid.inhibitors[x][y][scaleNdx])
+// calcNextVariationsSynchronously every pixel reads from the current grid and writes to the next one
+// so the result does not depend upon the order (or the number of workers) in which the pixels are visited
+// NB: the half built next grid is simply discarded if the context is done
+func (grid *tsGrid) calcNextVariationsSynchronously(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	grid.calcPrefixSums()
+	grid.calcFFTAverages()
+	if err := grid.inBandsUntilDone(ctx, grid.calcActivatorsAndInhibitors); err != nil {
+		return err
+	}
+	if err := grid.inBandsUntilDone(ctx, grid.calcNextVariations); err != nil {
+		return err
+	}
+	grid.grid, grid.next = grid.next, grid.grid
+	return nil
 }
 
-func (grid tsGrid) calcNextVariations() {
-	gridCenterX, gridCenterY := grid.Width/2, grid.Height/2
+// calcNextVariationsInPlace every pixel is sampled from, and written back to, the current grid
+// in a single sweep, so later pixels see the values already changed by earlier ones
+// NB: this depends upon the order in which the pixels are visited, so it is never done in parallel
+func (grid *tsGrid) calcNextVariationsInPlace(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// keep the previous values in the (otherwise unused) next grid, for calcChange
+	for y := 0; y < grid.Height; y++ {
+		copy(grid.next[y], grid.grid[y])
+	}
+
 	for x := 0; x < grid.Width; x++ {
 		for y := 0; y < grid.Height; y++ {
-			for k := 0; k < len(grid.scales); k++ {
-				// if symmetry > 1 then we effectively average this variation
-				// with that many samples taken from the same point (x, y) rotated around
-				// the image centre - this should result in an image symmetric around
-				// its center point
-				symmetry := float64(grid.scales[k].Symmetry)
-				variation := 0.0
-				for n := symmetry; n > 0.0; n-- {
-					x1, y1 := util.RotateAboutAngle(x, y, 360.0/n, gridCenterX, gridCenterY)
-					x1 = util.ConstrainInt(0, x1, grid.Width)
-					y1 = util.ConstrainInt(0, y1, grid.Height)
-					variation += grid.sampleXY(x1, y1, k)
+			if err := ctx.Err(); err != nil {
+				// restore the pixels already swept
+				for y := 0; y < grid.Height; y++ {
+					copy(grid.grid[y], grid.next[y])
 				}
-				grid.variations[x][y][k] = variation / symmetry
+				return err
 			}
+			grid.grid[y][x] = grid.nextValue(grid.liveSample, x, y)
+		}
+	}
+	return nil
+}
 
-			// best variation will be the smallest
-			var ( // begin with values that are arbitrary yet valid
-				ndx               = 0
-				bestVariation     = &grid.scales[0]
-				smallestVariation = grid.variations[x][y][0]
-			)
-			for k := 0; k < len(grid.scales); k++ {
-				if grid.variations[x][y][k] < smallestVariation {
-					ndx = k
-					bestVariation = &grid.scales[k]
-				}
+// inBands split the rows of this grid into bands, one per worker,
+// and call fn concurrently for each band of rows [y0, y1)
+func (grid *tsGrid) inBands(fn func(y0, y1 int)) {
+	util.InParallel(grid.Height, grid.Workers, fn)
+}
+
+// inBandsUntilDone call fn for the rows of each band as inBands does, one row at a time,
+// with each band stopping early once the context is done, whose error is then returned
+func (grid *tsGrid) inBandsUntilDone(ctx context.Context, fn func(y0, y1 int)) error {
+	grid.inBands(func(y0, y1 int) {
+		for y := y0; y < y1 && ctx.Err() == nil; y++ {
+			fn(y, y+1)
+		}
+	})
+	return ctx.Err()
+}
+
+// calcDirectPad the number of pixels beyond each edge needed by the directly summed kernels
+// NB: the prefix sums clip kernels to the edges themselves, so the clip boundary needs no padding
+func (grid *tsGrid) calcDirectPad() {
+	grid.directPad = 0
+	if grid.boundary == util.BoundaryClip {
+		return
+	}
+	for k := range grid.scales {
+		scale := &grid.scales[k]
+		if grid.activatorFFTs[k] == nil && scale.ActivatorRadius > grid.directPad {
+			grid.directPad = scale.ActivatorRadius
+		}
+		if grid.inhibitorFFTs[k] == nil && scale.InhibitorRadius > grid.directPad {
+			grid.directPad = scale.InhibitorRadius
+		}
+	}
+}
+
+// calcPrefixSums build the prefix sums of the current grid needed by the kernels of its scales
+func (grid *tsGrid) calcPrefixSums() {
+	grid.rowSums, grid.areaSums = nil, nil
+
+	values := grid.grid
+	if grid.boundary != util.BoundaryClip {
+		values = util.PadGrid(grid.grid, grid.directPad, grid.boundary, grid.boundaryValue)
+	}
+	for k := range grid.scales {
+		if grid.activatorFFTs[k] != nil && grid.inhibitorFFTs[k] != nil {
+			continue
+		}
+		switch grid.scales[k].Kernel {
+		case kernelSquare:
+			if grid.areaSums == nil {
+				grid.areaSums = util.MakeSummedAreaTable(values)
+			}
+		default:
+			if grid.rowSums == nil {
+				grid.rowSums = util.MakeRowPrefixSums(values)
+			}
+		}
+	}
+}
+
+// averageWithinKernel return the average of the current grid values within
+// this scale's kernel of the given radius, centred on x, y
+func (grid *tsGrid) averageWithinKernel(scale *turingScale, fft *fftKernel, x, y, radius int) float64 {
+	if fft != nil {
+		return fft.averages[y][x]
+	}
+	x, y = x+grid.directPad, y+grid.directPad
+	if scale.Kernel == kernelSquare {
+		return grid.areaSums.AverageOfPixelsWithinSquare(x, y, radius)
+	}
+	return grid.rowSums.AverageOfPixelsWithinCircle(x, y, radius)
+}
+
+// calcActivatorsAndInhibitors build the activator and inhibitor maps of every scale for rows [y0, y1)
+// these maps are calculated once per iteration, then sampled by calcNextVariations
+func (grid *tsGrid) calcActivatorsAndInhibitors(y0, y1 int) {
+	for y := y0; y < y1; y++ {
+		for x := 0; x < grid.Width; x++ {
+			for k := range grid.scales {
+				scale := &grid.scales[k]
+				grid.activators[y][x][k] = grid.averageWithinKernel(scale, grid.activatorFFTs[k], x, y, scale.ActivatorRadius) * scale.Weight
+				grid.inhibitors[y][x][k] = grid.averageWithinKernel(scale, grid.inhibitorFFTs[k], x, y, scale.InhibitorRadius) * scale.Weight
 			}
-			if grid.activators[x][y][ndx] > grid.inhibitors[x][y][ndx] {
-				grid.grid[x][y] += bestVariation.SmallAmount
-			} else {
-				grid.grid[x][y] -= bestVariation.SmallAmount
+		}
+	}
+}
+
+// sampler return the activator and inhibitor of the given scale at x,y
+type sampler func(x, y, scaleNdx int) (activator, inhibitor float64)
+
+// mapSample sample the activator and inhibitor maps calculated for this iteration
+func (grid *tsGrid) mapSample(x, y, scaleNdx int) (activator, inhibitor float64) {
+	return grid.activators[y][x][scaleNdx], grid.inhibitors[y][x][scaleNdx]
+}
+
+// liveSample sample the activator and inhibitor directly from the current grid values
+func (grid *tsGrid) liveSample(x, y, scaleNdx int) (activator, inhibitor float64) {
+	scale := &grid.scales[scaleNdx]
+	activator = grid.liveAverageWithinKernel(scale, x, y, scale.ActivatorRadius) * scale.Weight
+	inhibitor = grid.liveAverageWithinKernel(scale, x, y, scale.InhibitorRadius) * scale.Weight
+	return activator, inhibitor
+}
+
+// liveAverageWithinKernel return the average of the current grid values within
+// this scale's kernel of the given radius, centred on x, y, summed pixel by pixel
+func (grid *tsGrid) liveAverageWithinKernel(scale *turingScale, x, y, radius int) float64 {
+	sum, count := 0.0, 0
+	for j := y - radius; j <= y+radius; j++ {
+		for i := x - radius; i <= x+radius; i++ {
+			if scale.Kernel != kernelSquare && !util.PointIsWithinCircle(i, j, x, y, radius) {
+				continue
+			}
+			switch {
+			case 0 <= i && i < grid.Width && 0 <= j && j < grid.Height:
+				sum += grid.grid[j][i]
+			case grid.boundary == util.BoundaryClip:
+				continue
+			case grid.boundary == util.BoundaryConstant:
+				sum += grid.boundaryValue
+			default:
+				sum += grid.grid[util.BoundaryIndex(j, grid.Height, grid.boundary)][util.BoundaryIndex(i, grid.Width, grid.boundary)]
 			}
+			count++
 		}
 	}
+	return sum / float64(count)
 }
 
-func (grid tsGrid) normaliseGridValues() {
+// symmetricSample return the activator and inhibitor of the given scale at x,y
+// averaged with the same point rotated symmetry-1 times around the image centre
+// this should result in an image symmetric around its center point
+func (grid *tsGrid) symmetricSample(sample sampler, x, y, scaleNdx int) (activator, inhibitor float64) {
+	activator, inhibitor = sample(x, y, scaleNdx)
+
+	symmetry := grid.scales[scaleNdx].Symmetry
+	if symmetry <= 1 {
+		return activator, inhibitor
+	}
+
+	gridCenterX, gridCenterY := grid.Width/2, grid.Height/2
+	for n := 1; n < symmetry; n++ {
+		angle := 360.0 * float64(n) / float64(symmetry)
+		xr, yr := util.RotateAboutAngle(x, y, angle, gridCenterX, gridCenterY)
+		xr = util.BoundaryIndex(xr, grid.Width, grid.boundary)
+		yr = util.BoundaryIndex(yr, grid.Height, grid.boundary)
+		a, i := sample(xr, yr, scaleNdx)
+		activator += a
+		inhibitor += i
+	}
+	return activator / float64(symmetry), inhibitor / float64(symmetry)
+}
+
+// calcNextVariations calculate the next value of every pixel in rows [y0, y1)
+// NB: this only writes to rows [y0, y1) of the next grid and the variations buffer
+func (grid *tsGrid) calcNextVariations(y0, y1 int) {
+	for y := y0; y < y1; y++ {
+		for x := 0; x < grid.Width; x++ {
+			grid.next[y][x] = grid.nextValue(grid.mapSample, x, y)
+		}
+	}
+}
+
+// nextValue return the next value of the pixel at x,y, changed by the scale with the smallest variation
+// NB: that scale is recorded as the pixel's winner
+func (grid *tsGrid) nextValue(sample sampler, x, y int) float64 {
+	// best variation will be the smallest
+	var ( // begin with values that are arbitrary yet valid
+		bestVariation     = 0
+		smallestVariation = math.Inf(1)
+		increase          = false
+	)
+	for k := range grid.scales {
+		activator, inhibitor := grid.symmetricSample(sample, x, y, k)
+
+		// the variation can be calculated as an average of values within an arbitrary radius from x,y
+		// but instead we use a radius of one pixel, i.e. just the value at x,y
+		// apparently a radius of one pixel produces "the sharpest, most detailed images"
+		grid.variations[y][x][k] = math.Abs(activator - inhibitor)
+
+		if grid.variations[y][x][k] < smallestVariation {
+			smallestVariation = grid.variations[y][x][k]
+			bestVariation = k
+			increase = activator > inhibitor
+		}
+	}
+	grid.winners[y][x] = bestVariation
+	if increase {
+		return grid.grid[y][x] + grid.scales[bestVariation].SmallAmount
+	}
+	return grid.grid[y][x] - grid.scales[bestVariation].SmallAmount
+}
+
+func (grid *tsGrid) normaliseGridValues() {
 	// normalise all grid values to scale them back between -1 and +1
 	// begin with the min and max values across the grid
 
 	var ( // begin with values that are arbitrary yet valid
 		smallest, largest = grid.grid[0][0], grid.grid[0][0]
 	)
-	for x := 0; x < grid.Width; x++ {
-		for y := 0; y < grid.Height; y++ {
-			smallest = math.Min(smallest, grid.grid[x][y])
-			largest = math.Max(largest, grid.grid[x][y])
+	for y := 0; y < grid.Height; y++ {
+		for x := 0; x < grid.Width; x++ {
+			smallest = math.Min(smallest, grid.grid[y][x])
+			largest = math.Max(largest, grid.grid[y][x])
 		}
 	}
 
-	for x := 0; x < grid.Width; x++ {
-		for y := 0; y < grid.Height; y++ {
-			grid.grid[x][y] = (grid.grid[x][y]-smallest)/(largest-smallest)*2 - 1
+	// a uniform grid has no range to scale, so it is set to the middle of the range instead
+	// NB: a uniform grid stays uniform, e.g. a 1x1 grid or a checkerboard whose squares cover a wrapped grid
+	if largest == smallest {
+		smallest, largest = smallest-1, smallest+1
+	}
+
+	grid.inBands(func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < grid.Width; x++ {
+				grid.grid[y][x] = (grid.grid[y][x]-smallest)/(largest-smallest)*2 - 1
+			}
+		}
+	})
+}
+
+// IterationChange how much the values of a grid changed in its latest iteration
+type IterationChange struct {
+	MeanDelta float64 // the mean absolute change of all values
+	MaxDelta  float64 // the largest absolute change of any value
+	SignFlips float64 // the fraction of values whose sign changed
+}
+
+// calcChange compare the current grid with the previous one, which is left in the next grid by each iteration
+func (grid *tsGrid) calcChange() {
+	change := IterationChange{}
+	flips := 0
+	for y := 0; y < grid.Height; y++ {
+		for x := 0; x < grid.Width; x++ {
+			previous, current := grid.next[y][x], grid.grid[y][x]
+			delta := math.Abs(current - previous)
+			change.MeanDelta += delta
+			change.MaxDelta = math.Max(change.MaxDelta, delta)
+			if (previous < 0) != (current < 0) {
+				flips++
+			}
 		}
 	}
+	pixels := float64(grid.Width * grid.Height)
+	change.MeanDelta /= pixels
+	change.SignFlips = float64(flips) / pixels
+	grid.change = change
 }
 
 // copyOfCurrentState return a copy of the current grid
-func (grid tsGrid) copyOfCurrentState() [][]float64 {
+func (grid *tsGrid) copyOfCurrentState() [][]float64 {
 	copy := util.Make2DGridFloat64(grid.Width, grid.Height)
-	for x := 0; x < grid.Width; x++ {
-		for y := 0; y < grid.Height; y++ {
-			copy[x][y] = grid.grid[x][y]
+	for y := 0; y < grid.Height; y++ {
+		for x := 0; x < grid.Width; x++ {
+			copy[y][x] = grid.grid[y][x]
 		}
 	}
 	return copy
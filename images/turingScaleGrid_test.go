@@ -0,0 +1,194 @@
+package images
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/dhodges/turing_patterns/util"
+)
+
+var testTuringScales = []turingScale{
+	turingScale{ActivatorRadius: 4, InhibitorRadius: 8, SmallAmount: 0.04, Weight: 1, Symmetry: 2},
+	turingScale{ActivatorRadius: 2, InhibitorRadius: 4, SmallAmount: 0.02, Weight: 1, Symmetry: 3, Kernel: kernelSquare},
+	turingScale{ActivatorRadius: 1, InhibitorRadius: 2, SmallAmount: 0.01, Weight: 1, Symmetry: 1},
+}
+
+func makeTestGrid(seed int64, workers int) *tsGrid {
+	grid := makeTuringScaleGrid(TSGridConfig{Width: 37, Height: 29, Scales: testTuringScales}, rand.New(rand.NewSource(seed)))
+	grid.Workers = workers
+	return grid
+}
+
+func TestNextIterationIsIndependentOfWorkers(t *testing.T) {
+	single := makeTestGrid(42, 1)
+	single.NextIteration(context.Background())
+	single.NextIteration(context.Background())
+
+	for _, workers := range []int{2, 3, 8, 64} {
+		parallel := makeTestGrid(42, workers)
+		parallel.NextIteration(context.Background())
+		parallel.NextIteration(context.Background())
+
+		for y := 0; y < single.Height; y++ {
+			for x := 0; x < single.Width; x++ {
+				if single.grid[y][x] != parallel.grid[y][x] {
+					t.Fatalf("with %d workers grid[%d][%d] is %v, but it should be %v", workers, y, x, parallel.grid[y][x], single.grid[y][x])
+				}
+			}
+		}
+	}
+}
+
+func TestWrapBoundaryIsTileable(t *testing.T) {
+	scales := []turingScale{
+		turingScale{ActivatorRadius: 3, InhibitorRadius: 6, SmallAmount: 0.05, Weight: 1, Symmetry: 1},
+		turingScale{ActivatorRadius: 1, InhibitorRadius: 2, SmallAmount: 0.02, Weight: 1, Symmetry: 1, Kernel: kernelSquare},
+		turingScale{ActivatorRadius: 6, InhibitorRadius: 12, SmallAmount: 0.03, Weight: 1, Symmetry: 1, Convolution: convolutionFFT},
+	}
+	cfg := TSGridConfig{Width: 32, Height: 24, Scales: scales, Boundary: util.BoundaryWrap}
+	grid := makeTuringScaleGrid(cfg, rand.New(rand.NewSource(3)))
+	shifted := makeTuringScaleGrid(cfg, rand.New(rand.NewSource(3)))
+
+	// on a torus, shifting the starting grid should only shift the pattern
+	dx, dy := 13, 5
+	for y := 0; y < grid.Height; y++ {
+		for x := 0; x < grid.Width; x++ {
+			shifted.grid[(y+dy)%grid.Height][(x+dx)%grid.Width] = grid.grid[y][x]
+		}
+	}
+	for i := 0; i < 10; i++ {
+		grid.NextIteration(context.Background())
+		shifted.NextIteration(context.Background())
+	}
+	for y := 0; y < grid.Height; y++ {
+		for x := 0; x < grid.Width; x++ {
+			expected := grid.grid[y][x]
+			actual := shifted.grid[(y+dy)%grid.Height][(x+dx)%grid.Width]
+			if math.Abs(expected-actual) > 1e-9 {
+				t.Fatalf("shifted grid at (%d, %d) is %v, but it should be %v", x, y, actual, expected)
+			}
+		}
+	}
+
+	if !util.IsTileable(grid.grid, 0.5) {
+		t.Errorf("a wrapped grid should be tileable, but its seam ratio is %v", util.TileSeamRatio(grid.grid))
+	}
+}
+
+func TestLiveSamplesAgreeWithMaps(t *testing.T) {
+	for _, boundary := range []string{util.BoundaryClip, util.BoundaryWrap, util.BoundaryMirror, util.BoundaryConstant} {
+		cfg := TSGridConfig{Width: 23, Height: 17, Scales: testTuringScales, Boundary: boundary, BoundaryValue: -0.25}
+		grid := makeTuringScaleGrid(cfg, rand.New(rand.NewSource(5)))
+		grid.calcPrefixSums()
+		grid.calcFFTAverages()
+		grid.inBands(grid.calcActivatorsAndInhibitors)
+
+		for y := 0; y < grid.Height; y++ {
+			for x := 0; x < grid.Width; x++ {
+				for k := range grid.scales {
+					mapActivator, mapInhibitor := grid.mapSample(x, y, k)
+					liveActivator, liveInhibitor := grid.liveSample(x, y, k)
+					if math.Abs(mapActivator-liveActivator) > 1e-9 || math.Abs(mapInhibitor-liveInhibitor) > 1e-9 {
+						t.Fatalf("%s scale %d at (%d, %d) samples (%v, %v) live, but (%v, %v) from the maps",
+							boundary, k, x, y, liveActivator, liveInhibitor, mapActivator, mapInhibitor)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestInPlaceUpdatesDependOnEarlierPixels(t *testing.T) {
+	synchronous := makeTuringScaleGrid(TSGridConfig{Width: 20, Height: 20, Scales: testTuringScales}, rand.New(rand.NewSource(11)))
+	inPlace := makeTuringScaleGrid(TSGridConfig{Width: 20, Height: 20, Scales: testTuringScales, UpdateMode: updateInPlace}, rand.New(rand.NewSource(11)))
+
+	synchronous.NextIteration(context.Background())
+	inPlace.NextIteration(context.Background())
+
+	differences := 0
+	for y := 0; y < synchronous.Height; y++ {
+		for x := 0; x < synchronous.Width; x++ {
+			if synchronous.grid[y][x] != inPlace.grid[y][x] {
+				differences++
+			}
+		}
+	}
+	if differences == 0 {
+		t.Errorf("in place and synchronous updates should differ once earlier pixels have changed")
+	}
+}
+
+func TestImagesWithTheSameSeedMatch(t *testing.T) {
+	first := MakeTSImageRGB(16, 12, 99)
+	MakeTSImageGray(16, 12, 1) // other images must not disturb the random values of the first
+	second := MakeTSImageRGB(16, 12, 99)
+
+	for y := 0; y < first.grid.Height; y++ {
+		for x := 0; x < first.grid.Width; x++ {
+			if first.grid.grid[y][x] != second.grid.grid[y][x] {
+				t.Fatalf("grid[%d][%d] is %v, but it should be %v", y, x, second.grid.grid[y][x], first.grid.grid[y][x])
+			}
+			if first.colors[y][x] != second.colors[y][x] {
+				t.Fatalf("colors[%d][%d] is %v, but it should be %v", y, x, second.colors[y][x], first.colors[y][x])
+			}
+		}
+	}
+
+	third := MakeTSImageRGB(16, 12, 100)
+	if third.Seed() != 100 || first.grid.grid[0][0] == third.grid.grid[0][0] {
+		t.Errorf("a different seed should start the image from different random values")
+	}
+}
+
+func TestIterationChange(t *testing.T) {
+	for _, mode := range []string{updateSynchronous, updateInPlace} {
+		grid := makeTuringScaleGrid(TSGridConfig{Width: 20, Height: 15, Scales: testTuringScales, UpdateMode: mode}, rand.New(rand.NewSource(8)))
+		previous := grid.copyOfCurrentState()
+		grid.NextIteration(context.Background())
+
+		expected := IterationChange{}
+		for y := 0; y < grid.Height; y++ {
+			for x := 0; x < grid.Width; x++ {
+				delta := math.Abs(grid.grid[y][x] - previous[y][x])
+				expected.MeanDelta += delta / float64(grid.Width*grid.Height)
+				expected.MaxDelta = math.Max(expected.MaxDelta, delta)
+				if (grid.grid[y][x] < 0) != (previous[y][x] < 0) {
+					expected.SignFlips += 1 / float64(grid.Width*grid.Height)
+				}
+			}
+		}
+		change := grid.change
+		if math.Abs(change.MeanDelta-expected.MeanDelta) > 1e-9 || change.MaxDelta != expected.MaxDelta ||
+			math.Abs(change.SignFlips-expected.SignFlips) > 1e-9 {
+			t.Errorf("%s change is %+v, but it should be %+v", mode, change, expected)
+		}
+		if change.MeanDelta == 0 {
+			t.Errorf("%s change should not be zero after the first iteration", mode)
+		}
+	}
+}
+
+func TestUniformGridsStayFinite(t *testing.T) {
+	// the checkerboard's default squares are larger than the grid, so every value begins as -1
+	cfg := TSGridConfig{Width: 16, Height: 16, Scales: testTuringScales, Boundary: util.BoundaryWrap,
+		InitialState: InitialState{Generator: "checkerboard"}}
+	img, err := NewTSImageGray(TSImageConfigGray{cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		img.NextIteration(context.Background())
+		for y := range img.grid.grid {
+			for x, value := range img.grid.grid[y] {
+				if value != 0 {
+					t.Fatalf("after iteration %d grid[%d][%d] is %v, but a uniform grid should be 0", i+1, y, x, value)
+				}
+			}
+		}
+	}
+	if change := img.LastChange(); change.MeanDelta != 0 || change.MaxDelta != 0 {
+		t.Errorf("a uniform grid should not change, but its change is %+v", change)
+	}
+}
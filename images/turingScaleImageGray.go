@@ -1,76 +1,173 @@
 package images
 
 import (
-	"encoding/json"
+	"context"
+	"fmt"
+	"image"
 	"image/color"
 	"io/ioutil"
-	"log"
 	"math"
+	"math/rand"
 
 	"github.com/dhodges/turing_patterns/util"
 )
 
 // TSImageGray a grayscale reaction/diffusion image (using turing scales)
 type TSImageGray struct {
-	grid *tsGrid
+	rng      *rand.Rand
+	source   *util.RandSource
+	grid     *tsGrid
+	colorMap ColorMap
+	lut      []color.NRGBA // the color of each pixel value, see ColorMap.lut
 }
 
 // TSImageConfigGray parameters
 type TSImageConfigGray struct {
-	Width  int
-	Height int
-	Scales []turingScale
+	TSGridConfig
 }
 
 // MakeTSImageGray return a TSImageGray with default values
-func MakeTSImageGray(width, height int) *TSImageGray {
-	return &TSImageGray{grid: makeTuringScaleGrid(width, height, defaultTuringScales)}
+func MakeTSImageGray(width, height int, seed int64) *TSImageGray {
+	rng, source := util.NewRand(seed)
+	img := &TSImageGray{
+		rng:    rng,
+		source: source,
+		grid:   makeTuringScaleGrid(TSGridConfig{Width: width, Height: height, Scales: defaultTuringScales}, rng),
+	}
+	img.SetColorMap(ColorMap{})
+	return img
+}
+
+// NewTSImageGray return a TSImageGray made from the given config
+// a Seed of 0 is replaced by one taken from the current time
+func NewTSImageGray(cfg TSImageConfigGray) (*TSImageGray, error) {
+	if err := cfg.validateOneGrid(); err != nil {
+		return nil, err
+	}
+	img := &TSImageGray{}
+	img.rng, img.source = util.NewRand(seedOf(cfg.TSGridConfig))
+	grid, err := newTuringScaleGrid(cfg.TSGridConfig, img.rng)
+	if err != nil {
+		return nil, err
+	}
+	img.grid = grid
+	if err := img.SetColorMap(cfg.ColorMap); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// SetColorMap color this image with the given gradient, from the next image generated
+// NB: this can recolor an image restored from a state file, without changing its values
+func (img *TSImageGray) SetColorMap(cm ColorMap) error {
+	lut, err := cm.lut()
+	if err != nil {
+		return err
+	}
+	img.colorMap, img.lut = cm, lut
+	return nil
 }
 
-// ConfigFromFile configures TSImageGray from the given file
-func (img TSImageGray) ConfigFromFile(configfile string) {
+// ConfigFromFile configures TSImageGray from the given file, keeping its seed unless the file has one, and its workers
+//
+// Deprecated: use DecodeConfig and NewTSImageGray, which return a new image
+func (img *TSImageGray) ConfigFromFile(configfile string) error {
 	file, err := ioutil.ReadFile(configfile)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	config := TSImageConfigGray{}
-	if err = json.Unmarshal([]byte(file), &config); err != nil {
-		log.Fatal(err)
+	if err = DecodeConfig(file, &config); err != nil {
+		return fmt.Errorf("%s: %v", configfile, err)
+	}
+	if config.Seed == 0 {
+		config.Seed = img.Seed()
 	}
 
-	img.initFromConfig(config)
+	configured, err := NewTSImageGray(config)
+	if err != nil {
+		return err
+	}
+	configured.SetWorkers(img.grid.Workers)
+	*img = *configured
+	return nil
+}
+
+// Seed return the seed of this image's random number generator
+func (img *TSImageGray) Seed() int64 {
+	return img.source.InitialSeed()
 }
 
-// initFromConfig configures TSImageGray from the given config
-func (img TSImageGray) initFromConfig(cfg TSImageConfigGray) {
-	img.grid = makeTuringScaleGrid(cfg.Width, cfg.Height, cfg.Scales)
+// SaveState write the state of this image, after the given iteration, to the given file
+func (img *TSImageGray) SaveState(filename string, iteration int) error {
+	state := gridState(ModelGray, iteration, img.grid, img.source)
+	state.Config.ColorMap = img.colorMap
+	return writeStateFile(filename, state)
+}
+
+// LoadState restore this image from the given state file, returning the iteration it had reached
+func (img *TSImageGray) LoadState(filename string) (int, error) {
+	state, err := readStateFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	if state.Model != ModelGray {
+		return 0, fmt.Errorf("%s: holds a %s image, not a %s image", filename, state.Model, ModelGray)
+	}
+	if err := img.SetColorMap(state.Config.ColorMap); err != nil {
+		return 0, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	workers := img.grid.Workers
+	img.grid, img.rng, img.source = restoreGrid(state)
+	img.grid.Workers = workers
+	return int(state.Iteration), nil
 }
 
 // NextIteration generate the next variation of this image
-func (img TSImageGray) NextIteration() {
-	img.grid.NextIteration()
+// if the context is done before the iteration is complete, the image is left unchanged and the context's error is returned
+func (img *TSImageGray) NextIteration(ctx context.Context) error {
+	return img.grid.NextIteration(ctx)
+}
+
+// LastChange return how much this image changed in its latest iteration
+func (img *TSImageGray) LastChange() IterationChange {
+	return img.grid.change
+}
+
+// Config return the effective config of this image, including its seed
+func (img *TSImageGray) Config() TSGridConfig {
+	cfg := img.grid.config()
+	cfg.Seed = img.Seed()
+	cfg.ColorMap = img.colorMap
+	return cfg
+}
+
+// SetWorkers set the number of goroutines used to calculate each iteration
+func (img *TSImageGray) SetWorkers(workers int) {
+	img.grid.Workers = workers
+}
+
+// Image return the current iteration as an image
+func (img *TSImageGray) Image() image.Image {
+	return util.PixmapImage(img.pixmap())
 }
 
-// OutputPNG generate a PNG file from the current iteration
-func (img TSImageGray) OutputPNG(filename string) {
-	util.OutputPNG(filename, img.pixmap())
+// OutputPNG generate a PNG file from the current iteration, embedding the given metadata
+func (img *TSImageGray) OutputPNG(filename string, meta Metadata) error {
+	return util.OutputPNG(filename, img.pixmap(), meta.Text())
 }
 
-// pixmap return a grayscale pixmap derived from the current state of grid values
-func (img TSImageGray) pixmap() [][]color.NRGBA {
+// pixmap return a pixmap derived from the current state of grid values, colored by the image's color map
+func (img *TSImageGray) pixmap() [][]color.NRGBA {
 	pixels := util.Make2DGridNRGBA(img.grid.Width, img.grid.Height)
 
-	// map all grid values to a pixel grayscale value
-	for x := 0; x < img.grid.Width; x++ {
-		for y := 0; y < img.grid.Height; y++ {
-			gray := uint8(math.Trunc((img.grid.grid[x][y] + 1) / 2 * 255))
-			pixels[x][y] = color.NRGBA{
-				R: uint8(gray),
-				G: uint8(gray),
-				B: uint8(gray),
-				A: 255,
-			}
+	// map all grid values to a pixel grayscale value, then look up its color
+	for y := 0; y < img.grid.Height; y++ {
+		for x := 0; x < img.grid.Width; x++ {
+			gray := uint8(math.Trunc((img.grid.grid[y][x] + 1) / 2 * 255))
+			pixels[y][x] = img.lut[gray]
 		}
 	}
 	return pixels
@@ -1,10 +1,13 @@
 package images
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"image"
 	"image/color"
 	"io/ioutil"
-	"log"
+	"math/rand"
 
 	"github.com/dhodges/turing_patterns/hsb"
 	"github.com/dhodges/turing_patterns/util"
@@ -12,113 +15,203 @@ import (
 
 // TSImageRGB an RGB reaction/diffusion image (using turing scales)
 type TSImageRGB struct {
-	grid   *tsGrid
-	colors [][]hsb.NHSBA
+	rng         *rand.Rand
+	source      *util.RandSource
+	grid        *tsGrid
+	colors      [][]hsb.NHSBA
+	colorMode   string          // the name of the color mode, see colorModes
+	colorParams json.RawMessage // its params
+	recolor     colorMode
 }
 
 // TSImageConfigRGB parameters that define the image
 type TSImageConfigRGB struct {
-	Width  int
-	Height int
-	Scales []turingScale
+	TSGridConfig
 }
 
 // MakeTSImageRGB returns a TSImageRGB with default values
-func MakeTSImageRGB(width, height int) *TSImageRGB {
+func MakeTSImageRGB(width, height int, seed int64) *TSImageRGB {
+	rng, source := util.NewRand(seed)
 	img := &TSImageRGB{
-		grid:   makeTuringScaleGrid(width, height, defaultTuringScales),
+		rng:    rng,
+		source: source,
+		grid:   makeTuringScaleGrid(TSGridConfig{Width: width, Height: height, Scales: defaultTuringScales}, rng),
 		colors: util.Make2DGridNHSBA(width, height),
 	}
-	// NB: store all colors as HSB, defaulting to a random hue
-	for x := 0; x < width; x++ {
-		for y := 0; y < height; y++ {
-			img.colors[x][y] = hsb.NHSBA{H: util.RandFloat64(0.0, 360.0), S: 0.5, B: 1.0}
+	img.randomiseColors()
+	img.setColorMode(img.grid.config())
+	return img
+}
+
+// NewTSImageRGB return a TSImageRGB made from the given config
+// a Seed of 0 is replaced by one taken from the current time
+func NewTSImageRGB(cfg TSImageConfigRGB) (*TSImageRGB, error) {
+	if err := cfg.validateOneGrid(); err != nil {
+		return nil, err
+	}
+	img := &TSImageRGB{}
+	img.rng, img.source = util.NewRand(seedOf(cfg.TSGridConfig))
+	grid, err := newTuringScaleGrid(cfg.TSGridConfig, img.rng)
+	if err != nil {
+		return nil, err
+	}
+	img.grid = grid
+	img.colors = util.Make2DGridNHSBA(cfg.Width, cfg.Height)
+	img.randomiseColors()
+	if err := img.setColorMode(cfg.TSGridConfig); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// randomiseColors NB: store all colors as HSB, defaulting to a random hue
+func (img *TSImageRGB) randomiseColors() {
+	for y := 0; y < img.grid.Height; y++ {
+		for x := 0; x < img.grid.Width; x++ {
+			img.colors[y][x] = hsb.NHSBA{H: util.RandFloat64(img.rng, 0.0, 360.0), S: 0.5, B: 1.0, A: 1.0}
 		}
 	}
-	return img
 }
 
-// ConfigFromFile configures TSImageRGB from the given file
-func (img TSImageRGB) ConfigFromFile(configfile string) {
+// setColorMode set the color mode, and its params, to those of the given config
+func (img *TSImageRGB) setColorMode(cfg TSGridConfig) error {
+	name := colorModeOf(cfg)
+	params := colorParamsOf(cfg)
+	mode, err := makeColorMode(name, params, cfg.Scales)
+	if err != nil {
+		return err
+	}
+	img.colorMode, img.colorParams, img.recolor = name, params, mode
+	return nil
+}
 
+// ConfigFromFile configures TSImageRGB from the given file, keeping its seed unless the file has one, and its workers
+//
+// Deprecated: use DecodeConfig and NewTSImageRGB, which return a new image
+func (img *TSImageRGB) ConfigFromFile(configfile string) error {
 	file, err := ioutil.ReadFile(configfile)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	config := TSImageConfigRGB{}
-	if err = json.Unmarshal([]byte(file), &config); err != nil {
-		log.Fatal(err)
+	if err = DecodeConfig(file, &config); err != nil {
+		return fmt.Errorf("%s: %v", configfile, err)
+	}
+	if config.Seed == 0 {
+		config.Seed = img.Seed()
 	}
 
-	img.initFromConfig(config)
+	configured, err := NewTSImageRGB(config)
+	if err != nil {
+		return err
+	}
+	configured.SetWorkers(img.grid.Workers)
+	*img = *configured
+	return nil
+}
+
+// Seed return the seed of this image's random number generator
+func (img *TSImageRGB) Seed() int64 {
+	return img.source.InitialSeed()
 }
 
-// initFromConfig configures TSImageRGB from the given config
-func (img TSImageRGB) initFromConfig(cfg TSImageConfigRGB) {
-	img.grid = makeTuringScaleGrid(cfg.Width, cfg.Height, cfg.Scales)
+// SaveState write the state of this image, after the given iteration, to the given file
+func (img *TSImageRGB) SaveState(filename string, iteration int) error {
+	state := gridState(ModelRGB, iteration, img.grid, img.source)
+	state.Config.ColorMode, state.Config.ColorParams = img.colorMode, img.colorParams
+	state.Colors = img.colors
+	return writeStateFile(filename, state)
+}
+
+// LoadState restore this image from the given state file, returning the iteration it had reached
+func (img *TSImageRGB) LoadState(filename string) (int, error) {
+	state, err := readStateFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	if state.Model != ModelRGB {
+		return 0, fmt.Errorf("%s: holds a %s image, not a %s image", filename, state.Model, ModelRGB)
+	}
+
+	if err := img.setColorMode(state.Config); err != nil {
+		return 0, fmt.Errorf("%s: %v", filename, err)
+	}
+	workers := img.grid.Workers
+	img.grid, img.rng, img.source = restoreGrid(state)
+	img.grid.Workers = workers
+	img.colors = state.Colors
+	return int(state.Iteration), nil
 }
 
 // NextIteration generates the next variation of this image
-func (img TSImageRGB) NextIteration() {
+// then colors each pixel by the image's color mode
+// if the context is done before the iteration is complete, the image is left unchanged and the context's error is returned
+func (img *TSImageRGB) NextIteration(ctx context.Context) error {
 
 	// we are interested in the change from the previous iteration to the next
 	previousGrid := img.copyOfCurrentState()
 
-	img.grid.NextIteration()
+	if err := img.grid.NextIteration(ctx); err != nil {
+		return err
+	}
 
-	for x := 0; x < img.grid.Width; x++ {
-		for y := 0; y < img.grid.Height; y++ {
-			delta := img.grid.grid[x][y] - previousGrid[x][y]
-			img.colors[x][y] = updateColor(img.colors[x][y], delta)
+	img.grid.inBands(func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < img.grid.Width; x++ {
+				img.colors[y][x] = img.recolor(pixel{
+					color:  img.colors[y][x],
+					value:  img.grid.grid[y][x],
+					delta:  img.grid.grid[y][x] - previousGrid[y][x],
+					winner: img.grid.winners[y][x],
+				})
+			}
 		}
-	}
+	})
+	return nil
 }
 
-// c: the previous version of this color
-// delta: [-1.0 <= delta <= 1.0], indicating the change in color
-func updateColor(c hsb.NHSBA, delta float64) hsb.NHSBA {
-	return hsb.NHSBA{H: ((delta + 1) / 2) * 360.0, S: 1.0, B: 1.0, A: 1.0}
+// copyOfCurrentState return a copy of the current grid
+func (img *TSImageRGB) copyOfCurrentState() [][]float64 {
+	return img.grid.copyOfCurrentState()
 }
 
-// c: the previous version of this color
-// delta: [-1.0 <= delta <= 1.0], indicating the change in color
-func updateColorGrainy(c hsb.NHSBA, delta float64) hsb.NHSBA {
-	newColor := hsb.NHSBA{H: c.H, S: c.S, B: c.B, A: c.A}
-
-	delta = util.ToFixed(delta*100, 2)
+// LastChange return how much this image changed in its latest iteration
+func (img *TSImageRGB) LastChange() IterationChange {
+	return img.grid.change
+}
 
-	if delta != 0.0 {
-		if 0.0 < c.H && c.H < 360.0 {
-			newColor.H = util.Constrain(0.0, c.H+delta, 360.0)
-		} else if 0.0 < c.S && c.S < 1.0 {
-			newColor.S = util.Constrain(0.0, c.S+delta, 1.0)
-		} else if 0.0 < c.B && c.B < 1.0 {
-			newColor.B = util.Constrain(0.0, c.B+delta, 1.0)
-		}
-	}
+// Config return the effective config of this image, including its seed
+func (img *TSImageRGB) Config() TSGridConfig {
+	cfg := img.grid.config()
+	cfg.Seed = img.Seed()
+	cfg.ColorMode, cfg.ColorParams = img.colorMode, img.colorParams
+	return cfg
+}
 
-	return newColor
+// SetWorkers set the number of goroutines used to calculate each iteration
+func (img *TSImageRGB) SetWorkers(workers int) {
+	img.grid.Workers = workers
 }
 
-// copyOfCurrentState return a copy of the current grid
-func (img TSImageRGB) copyOfCurrentState() [][]float64 {
-	return img.grid.copyOfCurrentState()
+// Image return the current iteration as an image
+func (img *TSImageRGB) Image() image.Image {
+	return util.PixmapImage(img.pixmap())
 }
 
-// OutputPNG generate a PNG file from the current iteration
-func (img TSImageRGB) OutputPNG(filename string) {
-	util.OutputPNG(filename, img.pixmap())
+// OutputPNG generate a PNG file from the current iteration, embedding the given metadata
+func (img *TSImageRGB) OutputPNG(filename string, meta Metadata) error {
+	return util.OutputPNG(filename, img.pixmap(), meta.Text())
 }
 
 // pixmap return a grayscale pixmap derived from the current state of grid values
-func (img TSImageRGB) pixmap() [][]color.NRGBA {
+func (img *TSImageRGB) pixmap() [][]color.NRGBA {
 	pixels := util.Make2DGridNRGBA(img.grid.Width, img.grid.Height)
 
 	// map all grid values to a pixel grayscale value
-	for x := 0; x < img.grid.Width; x++ {
-		for y := 0; y < img.grid.Height; y++ {
-			pixels[x][y] = *img.colors[x][y].ToNRGBA()
+	for y := 0; y < img.grid.Height; y++ {
+		for x := 0; x < img.grid.Width; x++ {
+			pixels[y][x] = *img.colors[y][x].ToNRGBA()
 		}
 	}
 	return pixels
@@ -0,0 +1,225 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/dhodges/turing_patterns/util"
+)
+
+// TSImageRGB3 an RGB reaction/diffusion image whose red, green and blue are each a separate grid of turing scales
+// the channels may be weakly coupled, each taking a fraction of its values from the other two
+type TSImageRGB3 struct {
+	cfg      TSGridConfig // the effective config, with the seed and scales of every channel
+	channels [3]*channel
+}
+
+// TSImageConfigRGB3 parameters
+type TSImageConfigRGB3 struct {
+	TSGridConfig
+}
+
+// ChannelConfig the grid of one channel of a TSImageRGB3
+type ChannelConfig struct {
+	Seed   int64         // the seed of the channel's random number generator, or 0 for the image's Seed plus the channel's index
+	Scales []turingScale // the channel's scales, or none for the image's Scales
+}
+
+// channel the grid of one color of a TSImageRGB3, with its own random number generator
+type channel struct {
+	rng    *rand.Rand
+	source *util.RandSource
+	grid   *tsGrid
+}
+
+// MakeTSImageRGB3 return a TSImageRGB3 with default values
+func MakeTSImageRGB3(width, height int, seed int64) *TSImageRGB3 {
+	img, _ := NewTSImageRGB3(TSImageConfigRGB3{TSGridConfig{Width: width, Height: height, Seed: seed, Scales: defaultTuringScales}})
+	return img
+}
+
+// NewTSImageRGB3 return a TSImageRGB3 made from the given config
+// a Seed of 0 is replaced by one taken from the current time
+func NewTSImageRGB3(cfg TSImageConfigRGB3) (*TSImageRGB3, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	img := &TSImageRGB3{cfg: cfg.TSGridConfig}
+	img.cfg.Seed = seedOf(cfg.TSGridConfig)
+	img.cfg.Channels = make([]ChannelConfig, len(img.channels))
+	for k := range img.channels {
+		ch := ChannelConfig{}
+		if k < len(cfg.Channels) {
+			ch = cfg.Channels[k]
+		}
+		if ch.Seed == 0 {
+			ch.Seed = img.cfg.Seed + int64(k)
+		}
+		if len(ch.Scales) == 0 {
+			ch.Scales = cfg.Scales
+		}
+		img.cfg.Channels[k] = ch
+
+		c := &channel{}
+		c.rng, c.source = util.NewRand(ch.Seed)
+		grid, err := newTuringScaleGrid(img.channelGridConfig(k), c.rng)
+		if err != nil {
+			return nil, err
+		}
+		c.grid = grid
+		img.channels[k] = c
+	}
+	return img, nil
+}
+
+// channelGridConfig the config of the grid of the given channel
+func (img *TSImageRGB3) channelGridConfig(k int) TSGridConfig {
+	return TSGridConfig{
+		Width:         img.cfg.Width,
+		Height:        img.cfg.Height,
+		Scales:        img.cfg.Channels[k].Scales,
+		Boundary:      img.cfg.Boundary,
+		BoundaryValue: img.cfg.BoundaryValue,
+		UpdateMode:    img.cfg.UpdateMode,
+		InitialState:  img.cfg.InitialState,
+	}
+}
+
+// Seed return the seed of this image, from which those of its channels are derived
+func (img *TSImageRGB3) Seed() int64 {
+	return img.cfg.Seed
+}
+
+// SaveState write the state of this image, after the given iteration, to the given file
+// NB: the red channel is saved as the grid of the state, the green and blue channels follow it
+func (img *TSImageRGB3) SaveState(filename string, iteration int) error {
+	red := img.channels[0]
+	state := gridState(ModelRGB3, iteration, red.grid, red.source)
+	state.Config = img.Config()
+	for _, c := range img.channels[1:] {
+		state.Channels = append(state.Channels, channelState{
+			Seed:  c.source.InitialSeed(),
+			Draws: c.source.Draws(),
+			Grid:  c.grid.copyOfCurrentState(),
+		})
+	}
+	return writeStateFile(filename, state)
+}
+
+// LoadState restore this image from the given state file, returning the iteration it had reached
+func (img *TSImageRGB3) LoadState(filename string) (int, error) {
+	state, err := readStateFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	if state.Model != ModelRGB3 {
+		return 0, fmt.Errorf("%s: holds a %s image, not a %s image", filename, state.Model, ModelRGB3)
+	}
+	if len(state.Config.Channels) != len(img.channels) {
+		return 0, fmt.Errorf("%s: holds %d channels, not %d", filename, len(state.Config.Channels), len(img.channels))
+	}
+
+	workers := img.channels[0].grid.Workers
+	img.cfg = state.Config
+	channels := append([]channelState{{Seed: state.Seed, Draws: state.Draws, Grid: state.Grid}}, state.Channels...)
+	for k, saved := range channels {
+		c := &channel{grid: makeTuringScaleGrid(img.channelGridConfig(k), nil)}
+		c.grid.grid = saved.Grid
+		c.grid.Workers = workers
+		c.rng, c.source = util.NewRand(saved.Seed)
+		c.source.Skip(saved.Draws)
+		img.channels[k] = c
+	}
+	return int(state.Iteration), nil
+}
+
+// NextIteration generate the next variation of each channel, then couple them
+// if the context is done before the iteration is complete, every channel is left unchanged and the context's error is returned
+func (img *TSImageRGB3) NextIteration(ctx context.Context) error {
+	var previous [3]IterationChange
+	for k, c := range img.channels {
+		previous[k] = c.grid.change
+		if err := c.grid.NextIteration(ctx); err != nil {
+			for j := 0; j < k; j++ {
+				img.channels[j].grid.undoIteration(previous[j])
+			}
+			return err
+		}
+	}
+	img.couple()
+	return nil
+}
+
+// couple move the values of each channel the Coupling fraction of the way towards the mean of the other two
+func (img *TSImageRGB3) couple() {
+	coupling := img.cfg.Coupling
+	if coupling == 0 {
+		return
+	}
+	r, g, b := img.channels[0].grid.grid, img.channels[1].grid.grid, img.channels[2].grid.grid
+	img.channels[0].grid.inBands(func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := range r[y] {
+				vr, vg, vb := r[y][x], g[y][x], b[y][x]
+				r[y][x] = vr + coupling*((vg+vb)/2-vr)
+				g[y][x] = vg + coupling*((vr+vb)/2-vg)
+				b[y][x] = vb + coupling*((vr+vg)/2-vb)
+			}
+		}
+	})
+}
+
+// LastChange return how much this image changed in its latest iteration, over all of its channels
+// NB: the change is that of each channel before the channels were coupled
+func (img *TSImageRGB3) LastChange() IterationChange {
+	change := IterationChange{}
+	for _, c := range img.channels {
+		change.MeanDelta += c.grid.change.MeanDelta / float64(len(img.channels))
+		change.MaxDelta = math.Max(change.MaxDelta, c.grid.change.MaxDelta)
+		change.SignFlips += c.grid.change.SignFlips / float64(len(img.channels))
+	}
+	return change
+}
+
+// Config return the effective config of this image, including the seed and scales of each channel
+func (img *TSImageRGB3) Config() TSGridConfig {
+	cfg := img.cfg
+	cfg.Channels = append([]ChannelConfig(nil), img.cfg.Channels...)
+	return cfg
+}
+
+// SetWorkers set the number of goroutines used to calculate each iteration of each channel
+func (img *TSImageRGB3) SetWorkers(workers int) {
+	for _, c := range img.channels {
+		c.grid.Workers = workers
+	}
+}
+
+// Image return the current iteration as an image
+func (img *TSImageRGB3) Image() image.Image {
+	return util.PixmapImage(img.pixmap())
+}
+
+// OutputPNG generate a PNG file from the current iteration, embedding the given metadata
+func (img *TSImageRGB3) OutputPNG(filename string, meta Metadata) error {
+	return util.OutputPNG(filename, img.pixmap(), meta.Text())
+}
+
+// pixmap return a pixmap whose red, green and blue are the values of the corresponding channels
+func (img *TSImageRGB3) pixmap() [][]color.NRGBA {
+	pixels := util.Make2DGridNRGBA(img.cfg.Width, img.cfg.Height)
+	level := func(k, x, y int) uint8 {
+		return uint8(math.Trunc((img.channels[k].grid.grid[y][x] + 1) / 2 * 255))
+	}
+	for y := range pixels {
+		for x := range pixels[y] {
+			pixels[y][x] = color.NRGBA{R: level(0, x, y), G: level(1, x, y), B: level(2, x, y), A: 255}
+		}
+	}
+	return pixels
+}
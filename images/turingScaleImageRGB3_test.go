@@ -0,0 +1,93 @@
+package images
+
+import (
+	"context"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+func testRGB3Config(coupling float64) TSImageConfigRGB3 {
+	return TSImageConfigRGB3{TSGridConfig{
+		Width:    20,
+		Height:   14,
+		Seed:     6,
+		Scales:   testTuringScales,
+		Channels: []ChannelConfig{{}, {Seed: 99}, {Scales: testTuringScales[:1]}},
+		Coupling: coupling,
+	}}
+}
+
+func TestRGB3ChannelsAreIndependent(t *testing.T) {
+	img, err := NewTSImageRGB3(testRGB3Config(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img.NextIteration(context.Background())
+
+	// without coupling, each channel runs exactly as a grid of its own
+	for k, expected := range []ChannelConfig{{Seed: 6, Scales: testTuringScales}, {Seed: 99, Scales: testTuringScales}, {Seed: 8, Scales: testTuringScales[:1]}} {
+		grid := makeTuringScaleGrid(TSGridConfig{Width: 20, Height: 14, Scales: expected.Scales}, rand.New(rand.NewSource(expected.Seed)))
+		grid.NextIteration(context.Background())
+		for y := 0; y < grid.Height; y++ {
+			for x := 0; x < grid.Width; x++ {
+				if actual := img.channels[k].grid.grid[y][x]; actual != grid.grid[y][x] {
+					t.Fatalf("channel %d grid[%d][%d] is %v, but it should be %v", k, y, x, actual, grid.grid[y][x])
+				}
+			}
+		}
+		if ch := img.Config().Channels[k]; ch.Seed != expected.Seed || len(ch.Scales) != len(expected.Scales) {
+			t.Errorf("channel %d config is %+v, but it should be %+v", k, ch, expected)
+		}
+	}
+}
+
+func TestRGB3Coupling(t *testing.T) {
+	uncoupled, _ := NewTSImageRGB3(testRGB3Config(0))
+	coupled, _ := NewTSImageRGB3(testRGB3Config(0.25))
+	uncoupled.NextIteration(context.Background())
+	coupled.NextIteration(context.Background())
+
+	r, g, b := uncoupled.channels[0].grid.grid, uncoupled.channels[1].grid.grid, uncoupled.channels[2].grid.grid
+	expected := r[3][5] + 0.25*((g[3][5]+b[3][5])/2-r[3][5])
+	if actual := coupled.channels[0].grid.grid[3][5]; actual != expected {
+		t.Errorf("coupled red at (5, 3) is %v, but it should be %v", actual, expected)
+	}
+}
+
+func TestRGB3ResumeContinuesExactly(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "state.bin")
+	img, err := NewTSImageRGB3(testRGB3Config(0.1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img.NextIteration(context.Background())
+	if err := img.SaveState(filename, 1); err != nil {
+		t.Fatal(err)
+	}
+	img.NextIteration(context.Background())
+
+	resumed := MakeTSImageRGB3(2, 2, 0)
+	if iteration, err := resumed.LoadState(filename); err != nil || iteration != 1 {
+		t.Fatalf("resumed iteration is %d (%v), but it should be %d", iteration, err, 1)
+	}
+	resumed.NextIteration(context.Background())
+
+	if resumed.Seed() != 6 || resumed.Config().Coupling != 0.1 {
+		t.Errorf("resumed config is %+v, but it should be %+v", resumed.Config(), img.Config())
+	}
+	for k := range img.channels {
+		if resumed.channels[k].source.Draws() != img.channels[k].source.Draws() {
+			t.Errorf("resumed channel %d has drawn %d random values, but it should have drawn %d",
+				k, resumed.channels[k].source.Draws(), img.channels[k].source.Draws())
+		}
+		for y := 0; y < 14; y++ {
+			for x := 0; x < 20; x++ {
+				if resumed.channels[k].grid.grid[y][x] != img.channels[k].grid.grid[y][x] {
+					t.Fatalf("resumed channel %d grid[%d][%d] is %v, but it should be %v",
+						k, y, x, resumed.channels[k].grid.grid[y][x], img.channels[k].grid.grid[y][x])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package images
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/dhodges/turing_patterns/hsb"
+)
+
+func TestPixelsBlendTowardsTheWinningScale(t *testing.T) {
+	scales := DefaultScales()[2:]
+	scales[0].Color = "#ff8000"
+	cfg := TSImageConfigRGB{TSGridConfig{Width: 24, Height: 16, Seed: 7, Scales: scales, ColorParams: json.RawMessage(`{"Rate": 0.5}`)}}
+	img, err := NewTSImageRGB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orange, _ := hsb.ParseHex("#ff8000")
+
+	previous := make([][]hsb.NHSBA, len(img.colors))
+	for y := range img.colors {
+		previous[y] = append([]hsb.NHSBA(nil), img.colors[y]...)
+	}
+	img.NextIteration(context.Background())
+
+	winners := 0
+	for y := range img.colors {
+		for x := range img.colors[y] {
+			expected := previous[y][x]
+			if img.grid.winners[y][x] == 0 {
+				expected = hsb.Blend(expected, orange, 0.5)
+				winners++
+			}
+			if img.colors[y][x] != expected {
+				t.Fatalf("color at (%d, %d) is %+v, but it should be %+v", x, y, img.colors[y][x], expected)
+			}
+		}
+	}
+	if winners == 0 {
+		t.Errorf("the colored scale should win some pixels")
+	}
+	if cfg := img.Config(); cfg.ColorMode != "blend" || string(cfg.ColorParams) != `{"Rate": 0.5}` {
+		t.Errorf("the effective color mode is %q %s, but it should be blend at a rate of 0.5", cfg.ColorMode, cfg.ColorParams)
+	}
+}
+
+func TestColorBlendIsTheBlendRate(t *testing.T) {
+	scales := `[{"ActivatorRadius": 5, "InhibitorRadius": 10, "SmallAmount": 0.02, "Weight": 1, "Symmetry": 2, "Color": "#ff8000"},
+		{"ActivatorRadius": 1, "InhibitorRadius": 2, "SmallAmount": 0.01, "Weight": 1, "Symmetry": 2}]`
+	imgs := make([]*TSImageRGB, 2)
+	for i, contents := range []string{
+		`{"Width": 24, "Height": 16, "Seed": 7, "ColorBlend": 0.5, "Scales": ` + scales + `}`,
+		`{"Width": 24, "Height": 16, "Seed": 7, "ColorParams": {"Rate": 0.5}, "Scales": ` + scales + `}`,
+	} {
+		cfg := TSImageConfigRGB{}
+		if err := DecodeConfig([]byte(contents), &cfg); err != nil {
+			t.Fatal(err)
+		}
+		img, err := NewTSImageRGB(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img.NextIteration(context.Background())
+		imgs[i] = img
+	}
+
+	for y := range imgs[0].colors {
+		for x := range imgs[0].colors[y] {
+			if imgs[0].colors[y][x] != imgs[1].colors[y][x] {
+				t.Fatalf("color at (%d, %d) is %+v, but it should be %+v", x, y, imgs[0].colors[y][x], imgs[1].colors[y][x])
+			}
+		}
+	}
+
+	filename := filepath.Join(t.TempDir(), "state.tsps")
+	if err := imgs[0].SaveState(filename, 1); err != nil {
+		t.Fatal(err)
+	}
+	resumed := MakeTSImageRGB(1, 1, 0)
+	if _, err := resumed.LoadState(filename); err != nil {
+		t.Fatal(err)
+	}
+	if cfg := resumed.Config(); cfg.ColorMode != "blend" || string(cfg.ColorParams) != `{"Rate":0.5}` {
+		t.Errorf("the resumed color mode is %q %s, but it should be blend at a rate of 0.5", cfg.ColorMode, cfg.ColorParams)
+	}
+
+	cfg := TSGridConfig{Width: 8, Height: 8, Scales: imgs[0].grid.scales, ColorBlend: 0.5, ColorParams: json.RawMessage(`{"Rate": 0.5}`)}
+	if err := cfg.Validate(); err == nil {
+		t.Errorf("a ColorBlend should not be accepted alongside ColorParams")
+	}
+	cfg.ColorParams = json.RawMessage(`null`)
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("a ColorBlend should be accepted alongside null ColorParams, but: %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package images
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/dhodges/turing_patterns/hsb"
+	"github.com/dhodges/turing_patterns/util"
+)
+
+// ConfigErrors every problem found in a config, each beginning with the JSON path of the setting at fault
+type ConfigErrors []string
+
+func (errs ConfigErrors) Error() string {
+	return strings.Join(errs, "\n")
+}
+
+// validation collects the problems found in a config
+type validation struct {
+	errs ConfigErrors
+}
+
+// check record a problem with the setting at the given path, unless ok
+func (v *validation) check(ok bool, path, format string, args ...interface{}) {
+	if !ok {
+		v.errs = append(v.errs, path+" "+fmt.Sprintf(format, args...))
+	}
+}
+
+// checkOneOf record a problem with the setting at the given path, unless it has one of the given values
+// the empty string is allowed too, it selects the first (default) value
+func (v *validation) checkOneOf(value, path string, values ...string) {
+	if value == "" {
+		return
+	}
+	for _, allowed := range values {
+		if value == allowed {
+			return
+		}
+	}
+	v.errs = append(v.errs, fmt.Sprintf("%s must be one of %q, not %q", path, values, value))
+}
+
+// err the problems found, or nil if there are none
+func (v *validation) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// Validate report every problem with this config, as ConfigErrors
+func (cfg TSGridConfig) Validate() error {
+	v := validation{}
+	cfg.validate(&v, "")
+	return v.err()
+}
+
+// validateOneGrid report every problem with this config, for an image of one grid
+// NB: only an rgb3 image can take its scales from its channels
+func (cfg TSGridConfig) validateOneGrid() error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if len(cfg.Scales) == 0 {
+		return ConfigErrors{"Scales must have at least one scale"}
+	}
+	return nil
+}
+
+// validate record every problem with this config, whose settings are at the given JSON path prefix
+func (cfg TSGridConfig) validate(v *validation, prefix string) {
+	v.check(cfg.Width > 0, prefix+"Width", "must be > 0")
+	v.check(cfg.Height > 0, prefix+"Height", "must be > 0")
+	v.check(len(cfg.Scales) > 0 || cfg.channelsHaveScales(), prefix+"Scales", "must have at least one scale")
+	for i, scale := range cfg.Scales {
+		scale.validate(v, fmt.Sprintf("%sScales[%d].", prefix, i))
+	}
+	v.check(len(cfg.Channels) <= 3, prefix+"Channels", "must have at most 3 channels: red, green and blue")
+	for i, ch := range cfg.Channels {
+		for j, scale := range ch.Scales {
+			scale.validate(v, fmt.Sprintf("%sChannels[%d].Scales[%d].", prefix, i, j))
+		}
+	}
+	v.check(0 <= cfg.Coupling && cfg.Coupling <= 1, prefix+"Coupling", "must be between 0 and 1")
+	v.checkOneOf(cfg.Boundary, prefix+"Boundary", util.BoundaryClip, util.BoundaryWrap, util.BoundaryMirror, util.BoundaryConstant)
+	v.check(-1 <= cfg.BoundaryValue && cfg.BoundaryValue <= 1, prefix+"BoundaryValue", "must be between -1 and 1")
+	v.checkOneOf(cfg.UpdateMode, prefix+"UpdateMode", updateSynchronous, updateInPlace)
+	v.checkOneOf(cfg.ColorMode, prefix+"ColorMode", colorModeNames()...)
+	v.check(cfg.ColorMode != "blend" || hasScaleColors(cfg.Scales), prefix+"ColorMode", "\"blend\" needs a scale with a Color")
+	if cfg.ColorBlend != 0 {
+		v.check(colorModeOf(cfg) == "blend", prefix+"ColorBlend", "can only be used by the \"blend\" color mode")
+		v.check(!hasColorParams(cfg), prefix+"ColorBlend", "can not be used with ColorParams, whose Rate it is")
+		v.check(0 <= cfg.ColorBlend && cfg.ColorBlend <= 1, prefix+"ColorBlend", "must be between 0 and 1")
+	}
+	if makeMode, ok := colorModes[colorModeOf(cfg)]; ok {
+		_, err := makeMode(colorParamsOf(cfg), cfg.Scales)
+		v.check(err == nil, prefix+"ColorParams", "are invalid: %v", err)
+	}
+	cfg.InitialState.validate(v, prefix+"InitialState.")
+	cfg.ColorMap.validate(v, prefix+"ColorMap.")
+}
+
+// channelsHaveScales does each of the 3 channels, of an rgb3 image, have its own scales?
+func (cfg TSGridConfig) channelsHaveScales() bool {
+	if len(cfg.Channels) < 3 {
+		return false
+	}
+	for _, ch := range cfg.Channels {
+		if len(ch.Scales) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// validate record every problem with this scale, whose settings are at the given JSON path prefix
+func (scale turingScale) validate(v *validation, prefix string) {
+	v.check(scale.ActivatorRadius >= 0, prefix+"ActivatorRadius", "must be >= 0")
+	v.check(scale.InhibitorRadius > scale.ActivatorRadius, prefix+"InhibitorRadius", "must be > ActivatorRadius")
+	v.check(scale.SmallAmount >= 0, prefix+"SmallAmount", "must be >= 0")
+	v.check(scale.Weight >= 0, prefix+"Weight", "must be >= 0")
+	v.check(scale.Symmetry >= 1, prefix+"Symmetry", "must be >= 1")
+	v.checkOneOf(scale.Kernel, prefix+"Kernel", kernelCircle, kernelSquare)
+	v.checkOneOf(scale.Convolution, prefix+"Convolution", convolutionAuto, convolutionFFT, convolutionDirect)
+	if scale.Color != "" {
+		_, err := hsb.Parse(scale.Color)
+		v.check(err == nil, prefix+"Color", "is invalid: %v", err)
+	}
+}
+
+// validate record every problem with this initial state, whose settings are at the given JSON path prefix
+func (init InitialState) validate(v *validation, prefix string) {
+	if init.Generator != "" {
+		v.checkOneOf(init.Generator, prefix+"Generator", generatorNames()...)
+		if makeGen, ok := generators[init.Generator]; ok {
+			_, err := makeGen(init.Params)
+			v.check(err == nil, prefix+"Params", "are invalid: %v", err)
+		}
+	}
+	v.check(init.Generator == "" || init.Image == "", prefix+"Image", "cannot be used with a Generator")
+	v.checkOneOf(init.Resize, prefix+"Resize", resizeStretch, resizeFit, resizeFill, resizeNone)
+	v.check(0 <= init.Noise && init.Noise <= 1, prefix+"Noise", "must be between 0 and 1")
+}
+
+// validate record every problem with this color map, whose settings are at the given JSON path prefix
+func (cm ColorMap) validate(v *validation, prefix string) {
+	v.checkOneOf(cm.Name, prefix+"Name", colorMapNames()...)
+	v.check(cm.Name == "" || len(cm.Stops) == 0, prefix+"Stops", "cannot be used with a Name")
+	v.check(len(cm.Stops) != 1, prefix+"Stops", "must have at least 2 stops")
+	for i, stop := range cm.Stops {
+		_, err := hsb.Parse(stop)
+		v.check(err == nil, fmt.Sprintf("%sStops[%d]", prefix, i), "is invalid: %v", err)
+	}
+	v.checkOneOf(cm.Interpolation, prefix+"Interpolation", interpolateRGB, interpolateHSB, interpolateOKLab)
+}
+
+// DecodeConfig decode the given JSON into the given config, rejecting unknown fields as typos
+func DecodeConfig(contents []byte, cfg interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(cfg)
+}
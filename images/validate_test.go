@@ -0,0 +1,115 @@
+package images
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/dhodges/turing_patterns/util"
+)
+
+func TestValidateAcceptsTheDefaults(t *testing.T) {
+	cfg := TSGridConfig{Width: 20, Height: 10, Scales: DefaultScales()}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("the default scales should be valid, but: %v", err)
+	}
+}
+
+func TestValidateAcceptsASinglePixel(t *testing.T) {
+	// a 1x1 grid is always uniform, which normaliseGridValues must not divide by
+	for _, boundary := range []string{util.BoundaryClip, util.BoundaryWrap} {
+		cfg := TSGridConfig{Width: 1, Height: 1, Seed: 5, Boundary: boundary, Scales: DefaultScales()}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("%s: a 1x1 grid should be valid, but: %v", boundary, err)
+		}
+		img, err := NewTSImageGray(TSImageConfigGray{TSGridConfig: cfg})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			if err := img.NextIteration(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if value := img.grid.grid[0][0]; math.IsNaN(value) || math.IsInf(value, 0) {
+			t.Errorf("%s: the single pixel is %v after 3 iterations", boundary, value)
+		}
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	scales := DefaultScales()
+	scales[0].Symmetry = 0
+	scales[1].ActivatorRadius = -1
+	scales[2].InhibitorRadius = scales[2].ActivatorRadius
+	scales[3].Kernel = "hexagon"
+	cfg := TSGridConfig{
+		Width:        0,
+		Height:       10,
+		Scales:       scales,
+		Boundary:     "bounce",
+		InitialState: InitialState{Generator: "linear", Params: json.RawMessage(`{"Angel": 30}`), Noise: 2},
+	}
+
+	err := cfg.Validate()
+	errs, ok := err.(ConfigErrors)
+	if !ok {
+		t.Fatalf("the errors should be ConfigErrors, not %T (%v)", err, err)
+	}
+	expected := []string{
+		"Width must be > 0",
+		"Scales[0].Symmetry must be >= 1",
+		"Scales[1].ActivatorRadius must be >= 0",
+		"Scales[2].InhibitorRadius must be > ActivatorRadius",
+		`Scales[3].Kernel must be one of ["circle" "square"], not "hexagon"`,
+		"Boundary must be one of",
+		"InitialState.Params are invalid",
+		"InitialState.Noise must be between 0 and 1",
+	}
+	if len(errs) != len(expected) {
+		t.Errorf("%d problems are reported, but there should be %d:\n%v", len(errs), len(expected), err)
+	}
+	for _, problem := range expected {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("%q is not reported in:\n%v", problem, err)
+		}
+	}
+
+	cfg = TSGridConfig{Width: 10, Height: 10}
+	if err := cfg.Validate(); err == nil || err.Error() != "Scales must have at least one scale" {
+		t.Errorf("a config without scales should be reported, not %v", err)
+	}
+}
+
+func TestNewTSImageRejectsInvalidConfigs(t *testing.T) {
+	for _, contents := range []string{
+		`{"Width": 10, "Height": 10, "Scales": [{"ActivatorRadius": 1, "InhibitorRadius": 2, "SmallAmount": 0.01, "Weight": 1, "Symmetry": 0}]}`,
+		`{"Width": 10, "Height": 10, "Sacles": []}`,
+	} {
+		cfg := TSImageConfigGray{}
+		err := DecodeConfig([]byte(contents), &cfg)
+		if err == nil {
+			_, err = NewTSImageGray(cfg)
+		}
+		if err == nil {
+			t.Errorf("%s should be rejected", contents)
+		}
+	}
+}
+
+func TestValidateColorModes(t *testing.T) {
+	for _, test := range []struct {
+		mode, params, problem string
+	}{
+		{"sepia", ``, `ColorMode must be one of`},
+		{"blend", ``, `ColorMode "blend" needs a scale with a Color`},
+		{"value-hue", `{"Saturation": -1}`, `ColorParams are invalid: Saturation must be between 0 and 1`},
+	} {
+		cfg := TSGridConfig{Width: 10, Height: 10, Scales: DefaultScales(), ColorMode: test.mode, ColorParams: json.RawMessage(test.params)}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), test.problem) {
+			t.Errorf("%q is not reported in:\n%v", test.problem, err)
+		}
+	}
+}
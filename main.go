@@ -1,106 +1,564 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
 	"log"
-	"math/rand"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
 	"runtime/pprof"
+	"syscall"
 	"time"
 
 	"github.com/dhodges/turing_patterns/images"
+	"github.com/dhodges/turing_patterns/turing"
+	"github.com/dhodges/turing_patterns/util"
 )
 
 // profiling: https://blog.golang.org/profiling-go-programs
 
-var seed = time.Now().UnixNano()
-
-// TODO add flag to set the initial random seed
 // TODO add Dockerfile
-// TODO check whether the output image has stabilized; i.e. shows no significant change from the previous iteration
-// TODO flag to specify output directory for image files
-// TODO flag to specify dumping current state (and iteration number) of grid when exiting
-// TODO flag to specify initial state of grid
-// TODO flag to specify max n iterations
-// TODO generate animated PNGs
 
 var profilecpu = flag.String("profilecpu", "", "write cpu profile to file")
-var configfile = flag.String("configfile", "", "read image config from a json file")
+var configfile = flag.String("configfile", "", "read the config of the image, and of any other flag, from a json file")
 var saveNth = flag.Int("saveNth", 1, "save an image file for each nth iteration (default: save every iteration")
-var model = flag.String("model", "", "specify the generated color model ('gray' or 'rgb')")
+var model = flag.String("model", "", "specify the generated color model ('gray', 'rgb' or 'rgb3' with a grid per channel, default: the config's Model, otherwise 'gray')")
+var workers = flag.Int("workers", runtime.NumCPU(), "number of goroutines used to calculate each iteration")
+var seed = flag.Int64("seed", 0, "initial random seed (default: the config's Seed, otherwise the current time)")
+var statefile = flag.String("statefile", "state.bin", "the file to which the simulation state is saved (relative to -outdir)")
+var checkpointNth = flag.Int("checkpointNth", 0, "save the simulation state every nth iteration (default: only when interrupted)")
+var resume = flag.String("resume", "", "resume the simulation from the given state file")
+var untilStable = flag.Float64("until-stable", 0, "stop once the change in each iteration is below this threshold (default: never)")
+var stableMetric = flag.String("stable-metric", "mean", "the change compared with -until-stable: 'mean' or 'max' absolute change, or the fraction of sign 'flips'")
+var patience = flag.Int("patience", 5, "the number of consecutive iterations which must be below -until-stable")
+var colormap = flag.String("colormap", "", "color grayscale images with this built-in gradient: 'viridis', 'magma', 'inferno', 'twilight' or 'gray' (default: the config's ColorMap)")
+var initial = flag.String("initial", "", "begin from the luminance of the given PNG, JPEG or GIF file, rather than random noise")
+var outdir = flag.String("outdir", ".", "the directory to which image files, seed.txt, config.json and (relative) state files are written")
+var rundir = flag.Bool("rundir", false, "write this run's files to a new dated, sequentially numbered folder within -outdir, e.g. 2019_11_14_01")
+var apng = flag.String("apng", "", "also write each saved iteration as a frame of this animated PNG file (relative to -outdir)")
+var gifFile = flag.String("gif", "", "also write each saved iteration as a frame of this animated GIF file (relative to -outdir)")
+var gifPalette = flag.String("gif-palette", util.PaletteFrame, "quantise each GIF 'frame' to its own 256 colors, or every frame to one 'global' palette")
+var gifQuantizer = flag.String("gif-quantizer", util.QuantizeMedianCut, "how GIF palettes are chosen: 'median-cut' or 'octree'")
+var gifDither = flag.Bool("gif-dither", false, "apply Floyd-Steinberg dithering to GIF frames")
+var video = flag.String("video", "", "also write each saved iteration as a frame of this YUV4MPEG2 video file (relative to -outdir), or '-' for stdout")
+var fps = flag.Int("fps", 25, "the frame rate of the -video")
+var videoChroma = flag.String("video-chroma", util.Chroma420, "the chroma subsampling of the -video: '420' or '444'")
+var pngFrames = flag.Bool("png", true, "write each saved iteration to an image_NNN.png file (the final image is always written)")
+var frameDelay = flag.Duration("frame-delay", 100*time.Millisecond, "how long each frame of an animation is shown")
+var loops = flag.Int("loops", 0, "the number of times an animation plays (default: loop forever)")
+var iterations = flag.Int("iterations", 0, "stop after this many iterations (default: never)")
+var timeout = flag.Duration("timeout", 0, "stop once this much time has passed, abandoning any unfinished iteration, e.g. 30m (default: never)")
+
+// simConfig the config of the simulation, read from the -configfile
+var simConfig turing.Config
 
 func readFlags() {
 	flag.Parse()
-	if *profilecpu != "" {
-		f, err := os.Create(*profilecpu)
+	simConfig = readConfigFile()
+	checkFlags()
+}
+
+// checkFlags exit if any flag, or the config of the simulation, has an invalid value
+func checkFlags() {
+	cfg := runConfig{Config: simConfig}
+	cfg.readFlags()
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("invalid config:\n%v", err)
+	}
+}
+
+// commandArg the single argument of the command given after the flags, e.g. inspect image.png
+func commandArg() string {
+	if flag.NArg() != 2 {
+		log.Fatalf("usage: %s [flags] %s file", os.Args[0], flag.Arg(0))
+	}
+	return flag.Arg(1)
+}
+
+// startProfiling start the -profilecpu profile, if any
+// the returned function stops it, flushing the profile to its file
+func startProfiling() func() {
+	if *profilecpu == "" {
+		return func() {}
+	}
+	f, err := os.Create(*profilecpu)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		log.Fatal(err)
+	}
+	return func() {
+		pprof.StopCPUProfile()
+		if err := f.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// frameWriter an animation, to which a frame is added for each saved iteration
+type frameWriter interface {
+	AddFrame(image.Image) error
+	Close() error
+}
+
+// animations the animations being written by this run
+var animations []frameWriter
+
+// openAnimations begin each of the animations requested by the command line flags
+func openAnimations() {
+	if *apng != "" {
+		w, err := util.CreateAPNG(outputPath(*apng), *frameDelay, *loops)
+		if err != nil {
+			log.Fatal(err)
+		}
+		animations = append(animations, w)
+	}
+	if *gifFile != "" {
+		w, err := util.CreateGIF(outputPath(*gifFile), util.GIFOptions{
+			Delay:     *frameDelay,
+			Loops:     *loops,
+			Palette:   *gifPalette,
+			Quantizer: *gifQuantizer,
+			Dither:    *gifDither,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		animations = append(animations, w)
+	}
+	if *video != "" {
+		w, err := openVideo()
 		if err != nil {
 			log.Fatal(err)
 		}
-		pprof.StartCPUProfile(f)
+		animations = append(animations, w)
 	}
 }
 
-// IterativeImage generic interface
-type IterativeImage interface {
-	ConfigFromFile(string)
-	NextIteration()
-	OutputPNG(string)
+// stdout the standard output, which the -video is written to with '-video -'
+var stdout = os.Stdout
+
+// openVideo begin the -video file, or stream
+func openVideo() (frameWriter, error) {
+	if *video == "-" {
+		return util.NewY4MWriter(stdout, *fps, *videoChroma)
+	}
+	return util.CreateY4M(outputPath(*video), *fps, *videoChroma)
 }
 
-func setupImageDefault() IterativeImage {
-	width, height := 600, 600
+// closeAnimations finish writing each of the animations
+func closeAnimations() {
+	for _, w := range animations {
+		if err := w.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	animations = nil
+}
 
-	fmt.Println("width: ", width)
-	fmt.Println("height: ", height)
-	fmt.Println()
+// setupSimulation make the simulation described by the -configfile, and the flags which override it
+func setupSimulation() *turing.Simulation {
+	cfg := simConfig
+	cfg.Model = *model
+	cfg.Seed = *seed
+	if *initial != "" {
+		cfg.InitialState.Image = *initial
+	}
+	cfg.Workers = *workers
+	cfg.ColorMap = withColorMap(cfg.ColorMap)
 
-	switch *model {
-	case "rgb":
-		return images.MakeTSImageRGB(width, height)
-	case "gray":
-		return images.MakeTSImageGray(width, height)
+	sim, err := turing.New(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return sim
+}
+
+// resumeSimulation restore the simulation saved in the -resume state file
+func resumeSimulation() *turing.Simulation {
+	sim, err := turing.Resume(*resume)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sim.SetWorkers(*workers)
+	if *colormap != "" {
+		if err := sim.SetColorMap(withColorMap(sim.Config().ColorMap)); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	fmt.Printf("resuming %s after iteration %d\n", *resume, sim.Iteration())
+	return sim
+}
+
+// withColorMap the given color map, or the -colormap if one is given
+func withColorMap(cm images.ColorMap) images.ColorMap {
+	if *colormap == "" {
+		return cm
+	}
+	return images.ColorMap{Name: *colormap, Interpolation: cm.Interpolation}
+}
+
+func isSaveIteration(iteration int) bool {
+	return (*saveNth == 1) || (iteration%*saveNth == 0)
+}
+
+func saveImage(sim *turing.Simulation) {
+	if *pngFrames {
+		savePNG(sim)
+	}
+	if len(animations) > 0 {
+		frame := sim.Image()
+		for _, w := range animations {
+			if err := w.AddFrame(frame); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+}
+
+func savePNG(sim *turing.Simulation) {
+	writePNG(sim, outputPath(fmt.Sprintf("image_%03d.png", sim.Iteration())))
+}
+
+// writePNG write the current iteration of the given simulation to the given PNG file, with its metadata
+func writePNG(sim *turing.Simulation, filename string) {
+	meta := metadataOf(sim)
+	err := util.WriteFileAtomically(filename, func(w io.Writer) error {
+		return sim.EncodePNG(w, meta)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func optionallySave(sim *turing.Simulation) {
+	if isSaveIteration(sim.Iteration()) {
+		saveImage(sim)
+	}
+}
+
+// stableMetricOf the measure of the given change compared with -until-stable
+func stableMetricOf(change images.IterationChange) float64 {
+	switch *stableMetric {
+	case "max":
+		return change.MaxDelta
+	case "flips":
+		return change.SignFlips
 	default:
-		return images.MakeTSImageGray(width, height)
+		return change.MeanDelta
+	}
+}
+
+// stability counts the consecutive iterations whose change is below the -until-stable threshold
+type stability struct {
+	stableIterations int
+}
+
+// isStable has the image now been stable for -patience iterations?
+func (s *stability) isStable(sim *turing.Simulation) bool {
+	if *untilStable <= 0 {
+		return false
+	}
+	if stableMetricOf(sim.LastChange()) < *untilStable {
+		s.stableIterations++
+	} else {
+		s.stableIterations = 0
 	}
+	return s.stableIterations >= *patience
 }
 
-func setupImage() IterativeImage {
-	img := setupImageDefault()
+// outputPath the path of the given file within the output directory
+func outputPath(filename string) string {
+	if filepath.IsAbs(filename) {
+		return filename
+	}
+	return filepath.Join(outputDir, filename)
+}
 
-	if *configfile != "" {
-		img.ConfigFromFile(*configfile)
+// outputDir the directory to which this run's files are written: -outdir, or with -rundir a new run folder within it
+var outputDir string
+
+// makeOutputDir create the output directory, or with -rundir a new run folder within it
+func makeOutputDir() {
+	outputDir = *outdir
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		log.Fatal(err)
+	}
+	if *rundir {
+		dir, err := makeRunDir(outputDir, time.Now())
+		if err != nil {
+			log.Fatal(err)
+		}
+		outputDir = dir
+	}
+}
+
+// makeRunDir create the next run folder, named after the given date and numbered sequentially, within parent
+// e.g. parent/2019_11_14_01, then parent/2019_11_14_02...
+func makeRunDir(parent string, date time.Time) (string, error) {
+	prefix := date.Format("2006_01_02") + "_"
+	existing, err := filepath.Glob(filepath.Join(parent, prefix+"[0-9][0-9]*"))
+	if err != nil {
+		return "", err
+	}
+
+	next := 1
+	for _, dir := range existing {
+		n := 0
+		if _, err := fmt.Sscanf(filepath.Base(dir)[len(prefix):], "%d", &n); err == nil && n >= next {
+			next = n + 1
+		}
+	}
+
+	for ; ; next++ {
+		dir := filepath.Join(parent, fmt.Sprintf("%s%02d", prefix, next))
+		err := os.Mkdir(dir, 0755)
+		if err == nil {
+			return dir, nil
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+	}
+}
+
+// metadataOf the metadata embedded in the image file of the current iteration
+func metadataOf(sim *turing.Simulation) images.Metadata {
+	meta, err := sim.Metadata()
+	if err != nil {
+		log.Fatal(err)
+	}
+	meta.Config = string(runConfigJSON(sim))
+	return meta
+}
+
+// saveConfig record the effective config and seed alongside the image files, so that this run can be reproduced
+func saveConfig(sim *turing.Simulation) {
+	if err := ioutil.WriteFile(outputPath("config.json"), runConfigJSON(sim), 0644); err != nil {
+		log.Fatal(err)
 	}
 
-	return img
+	seed := fmt.Sprintf("%d\n", sim.Seed())
+	if err := ioutil.WriteFile(outputPath("seed.txt"), []byte(seed), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func optionallyCheckpoint(sim *turing.Simulation) {
+	if (*checkpointNth > 0) && (sim.Iteration()%*checkpointNth == 0) {
+		saveState(sim)
+	}
 }
 
-func optionallySave(img IterativeImage, iteration int) {
-	if (*saveNth == 1) || (iteration%*saveNth == 0) {
-		filename := fmt.Sprintf("image_%03d.png", iteration)
-		img.OutputPNG(filename)
+func saveState(sim *turing.Simulation) {
+	if err := sim.SaveState(outputPath(*statefile)); err != nil {
+		log.Fatal(err)
 	}
 }
 
+// watchSignals on the first SIGINT or SIGTERM, send it on the returned channel,
+// so that the current iteration can finish and the state be saved before the run stops;
+// on a second, give up and exit immediately
+func watchSignals() <-chan os.Signal {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	interrupted := make(chan os.Signal, 1)
+	go func() {
+		sig := <-signals
+		fmt.Printf("\n%v: stopping after this iteration (repeat to exit immediately)\n", sig)
+		interrupted <- sig
+		<-signals
+		os.Exit(1)
+	}()
+	return interrupted
+}
+
 func generateImages() {
-	img := setupImage()
+	sim := (*turing.Simulation)(nil)
+	if *resume != "" {
+		sim = resumeSimulation()
+	} else {
+		sim = setupSimulation()
+	}
+	makeOutputDir()
+	printInfo(sim)
+	saveConfig(sim)
 
-	for i := 1; i > 0; i++ {
-		fmt.Printf("iteration %3d...\r", i)
+	openAnimations()
+	// only the timeout cancels an iteration part way through, an interrupt waits for it to finish
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	interrupted := watchSignals()
 
-		img.NextIteration()
-		optionallySave(img, i)
+	stopReason := ""
+	stable := stability{}
+	for stopReason == "" {
+		fmt.Printf("iteration %3d...\r", sim.Iteration()+1)
+
+		if err := sim.Step(ctx); err != nil {
+			stopReason = fmt.Sprintf("timed out after %v", *timeout)
+			break
+		}
+		optionallySave(sim)
+		optionallyCheckpoint(sim)
+
+		if stable.isStable(sim) {
+			stopReason = fmt.Sprintf("stable, the %s change was below %v for %d iterations", *stableMetric, *untilStable, *patience)
+		}
+		if (*iterations > 0) && (sim.Iteration() >= *iterations) {
+			stopReason = fmt.Sprintf("reached %d iterations", *iterations)
+		}
+		select {
+		case sig := <-interrupted:
+			stopReason = fmt.Sprintf("%v, the state was saved to %s", sig, outputPath(*statefile))
+			saveState(sim)
+		default:
+		}
+	}
+
+	// always write the final image
+	if !isSaveIteration(sim.Iteration()) {
+		saveImage(sim)
+	}
+	if !*pngFrames {
+		savePNG(sim)
 	}
+	closeAnimations()
+	change := sim.LastChange()
+	fmt.Printf("\nstopped after iteration %d: %s\n", sim.Iteration(), stopReason)
+	fmt.Printf("last change: mean %.6f, max %.6f, sign flips %.4f%%\n", change.MeanDelta, change.MaxDelta, change.SignFlips*100)
 }
 
-func printInfo() {
-	fmt.Println("seed:  ", seed)
+// inspect print the metadata embedded in the given image file
+func inspect(filename string) {
+	meta, err := images.ReadMetadata(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("software: ", meta.Software)
+	fmt.Println("model:    ", meta.Model)
+	fmt.Println("seed:     ", meta.Seed)
+	fmt.Println("iteration:", meta.Iteration)
+	fmt.Println("config:")
+	fmt.Print(meta.Config)
+}
+
+// reproduce run the simulation described by the metadata embedded in the given image file,
+// up to the iteration at which that image was saved
+func reproduce(filename string) {
+	meta, err := images.ReadMetadata(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	f, err := ioutil.TempFile("", "config.*.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(meta.Config); err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := flag.Set("configfile", f.Name()); err != nil {
+		log.Fatal(err)
+	}
+	if err := flag.Set("iterations", fmt.Sprint(meta.Iteration)); err != nil {
+		log.Fatal(err)
+	}
+	// the output of the original run is not repeated, only that given by flags on the command line
+	for _, name := range []string{"outdir", "rundir", "statefile", "checkpointNth", "timeout", "png", "apng", "gif", "video"} {
+		f := flag.Lookup(name)
+		if err := flag.Set(name, f.Value.String()); err != nil {
+			log.Fatal(err)
+		}
+	}
+	simConfig = readConfigFile()
+	checkFlags()
+
+	fmt.Printf("reproducing %s (%s)\n", filename, meta.Software)
+	generateImages()
+}
+
+// recolor write the image saved in the given state file, colored by the -colormap,
+// otherwise by the ColorMap of the -configfile, otherwise by its own color map
+// NB: the simulation is not run, its saved values are only colored
+func recolor(filename string) {
+	sim, err := turing.Resume(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cm := sim.Config().ColorMap
+	if *configfile != "" {
+		cm = simConfig.ColorMap
+	}
+	if err := sim.SetColorMap(withColorMap(cm)); err != nil {
+		log.Fatal(err)
+	}
+
+	outputDir = *outdir
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		log.Fatal(err)
+	}
+	output := outputPath(fmt.Sprintf("recolored_%03d.png", sim.Iteration()))
+	writePNG(sim, output)
+	fmt.Printf("recolored %s after iteration %d: %s\n", filename, sim.Iteration(), output)
+}
+
+// validate check the given config file, printing every problem with it
+// NB: exits with a non-zero status if there are any, so that configs can be checked by CI
+func validate(filename string) {
+	cfg, err := loadRunConfig(filename)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s: ok\n", filename)
+}
+
+func printInfo(sim *turing.Simulation) {
+	cfg := sim.Config()
+	fmt.Println("width: ", cfg.Width)
+	fmt.Println("height: ", cfg.Height)
+	fmt.Println()
+	fmt.Println("seed:  ", cfg.Seed)
+	fmt.Println("workers:", cfg.Workers)
+	fmt.Println("output: ", outputDir)
 	if *saveNth > 1 {
 		fmt.Printf("saving every: %d iterations\n", *saveNth)
 	}
-	switch *model {
-	case "rgb":
+	if *iterations > 0 {
+		fmt.Println("iterations:", *iterations)
+	}
+	if *timeout > 0 {
+		fmt.Println("timeout:", *timeout)
+	}
+	if *untilStable > 0 {
+		fmt.Printf("until stable: %s change < %v for %d iterations\n", *stableMetric, *untilStable, *patience)
+	}
+	switch cfg.Model {
+	case images.ModelRGB:
 		fmt.Println("image: color")
+	case images.ModelRGB3:
+		fmt.Println("image: color, a grid per channel")
 	default:
 		fmt.Println("image: grayscale")
 	}
@@ -108,12 +566,25 @@ func printInfo() {
 
 func main() {
 	readFlags()
-	if *profilecpu != "" {
-		defer pprof.StopCPUProfile()
+	if *video == "-" {
+		// the video has stdout to itself, so everything else printed goes to stderr
+		os.Stdout = os.Stderr
 	}
+	stopProfiling := startProfiling()
+	defer stopProfiling()
 
-	rand.Seed(seed)
-
-	printInfo()
-	generateImages()
+	switch flag.Arg(0) {
+	case "":
+		generateImages()
+	case "inspect":
+		inspect(commandArg())
+	case "reproduce":
+		reproduce(commandArg())
+	case "validate":
+		validate(commandArg())
+	case "recolor":
+		recolor(commandArg())
+	default:
+		log.Fatalf("unknown command %q, expected 'inspect', 'reproduce', 'validate' or 'recolor'", flag.Arg(0))
+	}
 }
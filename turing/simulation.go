@@ -0,0 +1,299 @@
+// Package turing runs multi-scale turing pattern simulations, returning errors rather than exiting
+package turing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"io"
+	"io/ioutil"
+	"runtime"
+
+	"github.com/dhodges/turing_patterns/images"
+	"github.com/dhodges/turing_patterns/util"
+)
+
+// Version of this program, recorded in the metadata of every image
+const Version = "0.3.0"
+
+// image formats written by Encode
+const (
+	FormatPNG  = "png" // with the simulation's Metadata embedded
+	FormatJPEG = "jpeg"
+	FormatGIF  = "gif"
+)
+
+// Config everything which defines a Simulation
+type Config struct {
+	Model   string // images.ModelGray (default), images.ModelRGB or images.ModelRGB3
+	Workers int    // the number of goroutines used to calculate each iteration, or 0 for one per CPU
+	images.TSGridConfig
+}
+
+// DefaultConfig a 600x600 grayscale image, with the default turing scales and a seed taken from the current time
+func DefaultConfig() Config {
+	return Config{
+		Model: images.ModelGray,
+		TSGridConfig: images.TSGridConfig{
+			Width:  600,
+			Height: 600,
+			Scales: images.DefaultScales(),
+		},
+	}
+}
+
+// LoadConfig read a JSON config from the given file, any settings it omits are taken from DefaultConfig
+func LoadConfig(filename string) (Config, error) {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg := DefaultConfig()
+	if err := images.DecodeConfig(contents, &cfg); err != nil {
+		return Config{}, fmt.Errorf("%s: %v", filename, err)
+	}
+	return cfg, nil
+}
+
+// Validate report every problem with this config, as images.ConfigErrors
+func (cfg Config) Validate() error {
+	errs := images.ConfigErrors{}
+	switch cfg.Model {
+	case "", images.ModelGray, images.ModelRGB, images.ModelRGB3:
+		errs = append(errs, cfg.modelProblems()...)
+	default:
+		errs = append(errs, fmt.Sprintf("Model must be one of %q, not %q", []string{images.ModelGray, images.ModelRGB, images.ModelRGB3}, cfg.Model))
+	}
+	if cfg.Workers < 0 {
+		errs = append(errs, "Workers must be >= 0")
+	}
+	if err := cfg.TSGridConfig.Validate(); err != nil {
+		errs = append(errs, err.(images.ConfigErrors)...)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// modelProblems report each setting of this config which only another model uses
+func (cfg Config) modelProblems() []string {
+	model := cfg.Model
+	if model == "" {
+		model = images.ModelGray
+	}
+	problems := []string{}
+	onlyFor := func(only, field string, isSet bool) {
+		if isSet && model != only {
+			problems = append(problems, fmt.Sprintf("%s can only be used by the %q model", field, only))
+		}
+	}
+
+	onlyFor(images.ModelGray, "ColorMap", cfg.ColorMap.Name != "" || len(cfg.ColorMap.Stops) > 0 || cfg.ColorMap.Interpolation != "")
+	onlyFor(images.ModelRGB, "ColorMode", cfg.ColorMode != "")
+	onlyFor(images.ModelRGB, "ColorParams", len(cfg.ColorParams) > 0 && string(cfg.ColorParams) != "null")
+	onlyFor(images.ModelRGB, "ColorBlend", cfg.ColorBlend != 0)
+	for i, scale := range cfg.Scales {
+		onlyFor(images.ModelRGB, fmt.Sprintf("Scales[%d].Color", i), scale.Color != "")
+	}
+	onlyFor(images.ModelRGB3, "Channels", len(cfg.Channels) > 0)
+	onlyFor(images.ModelRGB3, "Coupling", cfg.Coupling != 0)
+	return problems
+}
+
+// model the images which a Simulation can run
+type model interface {
+	NextIteration(context.Context) error
+	LastChange() images.IterationChange
+	SetWorkers(int)
+	Seed() int64
+	Config() images.TSGridConfig
+	Image() image.Image
+	SaveState(string, int) error
+	LoadState(string) (int, error)
+}
+
+// Simulation a turing pattern image, and the number of iterations it has run
+type Simulation struct {
+	model     string
+	workers   int
+	img       model
+	iteration int
+}
+
+// New return a Simulation made from the given config
+func New(cfg Config) (*Simulation, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Model == "" {
+		cfg.Model = images.ModelGray
+	}
+
+	var img model
+	var err error
+	switch cfg.Model {
+	case images.ModelGray:
+		img, err = images.NewTSImageGray(images.TSImageConfigGray{TSGridConfig: cfg.TSGridConfig})
+	case images.ModelRGB:
+		img, err = images.NewTSImageRGB(images.TSImageConfigRGB{TSGridConfig: cfg.TSGridConfig})
+	case images.ModelRGB3:
+		img, err = images.NewTSImageRGB3(images.TSImageConfigRGB3{TSGridConfig: cfg.TSGridConfig})
+	default:
+		return nil, fmt.Errorf("unknown model %q, expected %q, %q or %q", cfg.Model, images.ModelGray, images.ModelRGB, images.ModelRGB3)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Simulation{model: cfg.Model, img: img}
+	s.SetWorkers(cfg.Workers)
+	return s, nil
+}
+
+// Resume return the Simulation saved in the given state file, see SaveState
+func Resume(filename string) (*Simulation, error) {
+	stateModel, err := images.StateModel(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var img model
+	switch stateModel {
+	case images.ModelGray:
+		img = images.MakeTSImageGray(1, 1, 0)
+	case images.ModelRGB:
+		img = images.MakeTSImageRGB(1, 1, 0)
+	case images.ModelRGB3:
+		img = images.MakeTSImageRGB3(1, 1, 0)
+	default:
+		return nil, fmt.Errorf("%s: unknown model %q", filename, stateModel)
+	}
+	iteration, err := img.LoadState(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Simulation{model: stateModel, img: img, iteration: iteration}
+	s.SetWorkers(0)
+	return s, nil
+}
+
+// SetWorkers set the number of goroutines used to calculate each iteration, or 0 for one per CPU
+// NB: the result of each iteration does not depend on the number of workers
+func (s *Simulation) SetWorkers(workers int) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	s.workers = workers
+	s.img.SetWorkers(workers)
+}
+
+// SetColorMap color the images of this simulation with the given gradient, see images.ColorMap
+// NB: only grayscale simulations have a color map
+func (s *Simulation) SetColorMap(cm images.ColorMap) error {
+	img, ok := s.img.(interface{ SetColorMap(images.ColorMap) error })
+	if !ok {
+		return fmt.Errorf("a %s simulation has no color map", s.model)
+	}
+	return img.SetColorMap(cm)
+}
+
+// Step calculate the next iteration
+// if the context is done before the iteration is complete, the image is left unchanged and the context's error is returned
+func (s *Simulation) Step(ctx context.Context) error {
+	if err := s.img.NextIteration(ctx); err != nil {
+		return err
+	}
+	s.iteration++
+	return nil
+}
+
+// Iteration the number of iterations calculated so far
+func (s *Simulation) Iteration() int {
+	return s.iteration
+}
+
+// LastChange how much the image changed in the latest iteration
+func (s *Simulation) LastChange() images.IterationChange {
+	return s.img.LastChange()
+}
+
+// Seed the seed of the simulation's random number generator
+func (s *Simulation) Seed() int64 {
+	return s.img.Seed()
+}
+
+// Config the effective config of this simulation, including its seed
+func (s *Simulation) Config() Config {
+	return Config{
+		Model:        s.model,
+		Workers:      s.workers,
+		TSGridConfig: s.img.Config(),
+	}
+}
+
+// Image the current iteration as an image
+func (s *Simulation) Image() image.Image {
+	return s.img.Image()
+}
+
+// Metadata how the current iteration was generated, the Config being this simulation's
+func (s *Simulation) Metadata() (images.Metadata, error) {
+	cfg, err := json.MarshalIndent(s.Config(), "", "  ")
+	if err != nil {
+		return images.Metadata{}, err
+	}
+	return images.Metadata{
+		Software:  "turing_patterns " + Version,
+		Model:     s.model,
+		Seed:      s.Seed(),
+		Iteration: s.iteration,
+		Config:    string(cfg) + "\n",
+	}, nil
+}
+
+// Encode write the current iteration as an image in the given format
+func (s *Simulation) Encode(w io.Writer, format string) error {
+	switch format {
+	case FormatPNG:
+		meta, err := s.Metadata()
+		if err != nil {
+			return err
+		}
+		return s.EncodePNG(w, meta)
+	case FormatJPEG:
+		return jpeg.Encode(w, s.Image(), &jpeg.Options{Quality: 95})
+	case FormatGIF:
+		return encodeGIF(w, s.Image())
+	default:
+		return fmt.Errorf("unknown image format %q, expected %q, %q or %q", format, FormatPNG, FormatJPEG, FormatGIF)
+	}
+}
+
+// EncodePNG write the current iteration as a PNG, embedding the given metadata
+func (s *Simulation) EncodePNG(w io.Writer, meta images.Metadata) error {
+	return util.EncodePNG(w, s.Image(), meta.Text())
+}
+
+// SaveState write the state of this simulation to the given file, so that it can be resumed exactly
+func (s *Simulation) SaveState(filename string) error {
+	return s.img.SaveState(filename, s.iteration)
+}
+
+// encodeGIF write the given image as a GIF, quantised to a median-cut palette
+func encodeGIF(w io.Writer, img image.Image) error {
+	histogram := util.ColorHistogram{}
+	histogram.Add(img)
+	palette, err := util.Quantize(histogram, 256, util.QuantizeMedianCut)
+	if err != nil {
+		return err
+	}
+	paletted := image.NewPaletted(img.Bounds(), palette)
+	draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), img, img.Bounds().Min)
+	return gif.Encode(w, paletted, nil)
+}
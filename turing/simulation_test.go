@@ -0,0 +1,298 @@
+package turing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dhodges/turing_patterns/images"
+	"github.com/dhodges/turing_patterns/util"
+)
+
+func testConfig(model string) Config {
+	return Config{
+		Model:   model,
+		Workers: 2,
+		TSGridConfig: images.TSGridConfig{
+			Width:  20,
+			Height: 14,
+			Seed:   3,
+			Scales: []images.Scale{
+				{ActivatorRadius: 3, InhibitorRadius: 6, SmallAmount: 0.05, Weight: 1, Symmetry: 2},
+				{ActivatorRadius: 1, InhibitorRadius: 2, SmallAmount: 0.02, Weight: 1, Symmetry: 1},
+			},
+		},
+	}
+}
+
+func TestNewAppliesTheConfig(t *testing.T) {
+	for _, model := range []string{images.ModelGray, images.ModelRGB, images.ModelRGB3} {
+		sim, err := New(testConfig(model))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sim.Step(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if bounds := sim.Image().Bounds(); bounds.Dx() != 20 || bounds.Dy() != 14 {
+			t.Errorf("%s: image is %dx%d, but it should be 20x14", model, bounds.Dx(), bounds.Dy())
+		}
+		cfg := sim.Config()
+		if cfg.Model != model || cfg.Seed != 3 || len(cfg.Scales) != 2 || sim.Iteration() != 1 {
+			t.Errorf("%s: effective config is %+v after iteration %d", model, cfg, sim.Iteration())
+		}
+	}
+}
+
+func TestNewRejectsUnknownModels(t *testing.T) {
+	if _, err := New(testConfig("cmyk")); err == nil {
+		t.Errorf("an unknown model should be rejected")
+	}
+}
+
+func TestStepIsCancelled(t *testing.T) {
+	sim, err := New(testConfig(images.ModelGray))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := sim.Step(ctx); err != context.Canceled {
+		t.Errorf("step returned %v, but it should return %v", err, context.Canceled)
+	}
+	if sim.Iteration() != 0 {
+		t.Errorf("a cancelled step should not calculate an iteration")
+	}
+}
+
+// cancelAfter a context which is cancelled once its Err has been checked the given number of times
+type cancelAfter struct {
+	context.Context
+	checks, limit int64
+}
+
+func (ctx *cancelAfter) Err() error {
+	if atomic.AddInt64(&ctx.checks, 1) > ctx.limit {
+		return context.Canceled
+	}
+	return nil
+}
+
+// pixels a copy of the pixels of the simulation's current image
+func pixels(sim *Simulation) []uint8 {
+	img := image.NewNRGBA(sim.Image().Bounds())
+	draw.Draw(img, img.Bounds(), sim.Image(), image.Point{}, draw.Src)
+	return img.Pix
+}
+
+func TestStepIsCancelledPartWayThrough(t *testing.T) {
+	for _, model := range []string{images.ModelRGB, images.ModelRGB3} {
+		for _, mode := range []string{"synchronous", "inplace"} {
+			cfg := testConfig(model)
+			cfg.UpdateMode = mode
+
+			// count the checks of a whole step, then cancel steps part way through them
+			sim, err := New(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			counted := &cancelAfter{Context: context.Background(), limit: math.MaxInt64}
+			if err := sim.Step(counted); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, limit := range []int64{0, counted.checks / 10, counted.checks / 2, counted.checks * 9 / 10} {
+				sim, err := New(cfg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				before := pixels(sim)
+
+				if err := sim.Step(&cancelAfter{Context: context.Background(), limit: limit}); err != context.Canceled {
+					t.Fatalf("%s %s: a step cancelled after %d of %d checks returned %v, but it should return %v", model, mode, limit, counted.checks, err, context.Canceled)
+				}
+				if sim.Iteration() != 0 {
+					t.Errorf("%s %s: a cancelled step should not count as an iteration", model, mode)
+				}
+				if !bytes.Equal(before, pixels(sim)) {
+					t.Errorf("%s %s: a step cancelled after %d of %d checks should leave the image unchanged", model, mode, limit, counted.checks)
+				}
+			}
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	sim, err := New(testConfig(images.ModelRGB))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	png := bytes.Buffer{}
+	if err := sim.Encode(&png, FormatPNG); err != nil {
+		t.Fatal(err)
+	}
+	text, err := util.DecodePNGText(&png)
+	if err != nil || text["Seed"] != "3" || text["Model"] != images.ModelRGB {
+		t.Errorf("PNG metadata is %v (%v)", text, err)
+	}
+
+	encoded := bytes.Buffer{}
+	if err := sim.Encode(&encoded, FormatJPEG); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jpeg.Decode(&encoded); err != nil {
+		t.Error(err)
+	}
+	encoded.Reset()
+	if err := sim.Encode(&encoded, FormatGIF); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gif.Decode(&encoded); err != nil {
+		t.Error(err)
+	}
+
+	if err := sim.Encode(&encoded, "bmp"); err == nil {
+		t.Errorf("an unknown format should be rejected")
+	}
+}
+
+func TestResume(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "state.bin")
+	sim, err := New(testConfig(images.ModelGray))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.Step(context.Background())
+	if err := sim.SaveState(filename); err != nil {
+		t.Fatal(err)
+	}
+	sim.Step(context.Background())
+
+	resumed, err := Resume(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed.Step(context.Background())
+	if resumed.Iteration() != 2 {
+		t.Errorf("resumed iteration is %d, but it should be 2", resumed.Iteration())
+	}
+
+	expected, actual := sim.Image(), resumed.Image()
+	bounds := expected.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if expected.At(x, y) != actual.At(x, y) {
+				t.Fatalf("resumed pixel (%d, %d) is %v, but it should be %v", x, y, actual.At(x, y), expected.At(x, y))
+			}
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(filename, []byte(`{"Model": "rgb", "Width": 40}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := DefaultConfig()
+	if cfg.Model != images.ModelRGB || cfg.Width != 40 || cfg.Height != defaults.Height || len(cfg.Scales) != len(defaults.Scales) {
+		t.Errorf("config is %+v, but it should be the defaults with the given model and width", cfg)
+	}
+
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("a missing config file should be an error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := testConfig("cmyk")
+	cfg.Workers = -1
+	cfg.Scales[1].InhibitorRadius = 0
+	err := cfg.Validate()
+	for _, problem := range []string{"Model must be one of", "Workers must be >= 0", "Scales[1].InhibitorRadius must be > ActivatorRadius"} {
+		if err == nil || !strings.Contains(err.Error(), problem) {
+			t.Errorf("%q is not reported in:\n%v", problem, err)
+		}
+	}
+	if _, err := New(cfg); err == nil {
+		t.Errorf("an invalid config should be rejected")
+	}
+
+	// the settings of every other model are reported
+	for model, problems := range map[string][]string{
+		images.ModelGray: {"ColorMode", "ColorParams", "Scales[0].Color", "Channels", "Coupling"},
+		images.ModelRGB:  {"ColorMap", "Channels", "Coupling"},
+		images.ModelRGB3: {"ColorMap", "ColorMode", "ColorParams", "Scales[0].Color"},
+	} {
+		cfg = testConfig(model)
+		cfg.ColorMap = images.ColorMap{Name: "viridis"}
+		cfg.ColorMode, cfg.ColorParams = "blend", json.RawMessage(`{"Rate": 0.5}`)
+		cfg.Scales[0].Color = "#ff8000"
+		cfg.Channels, cfg.Coupling = []images.ChannelConfig{{Seed: 1}}, 0.5
+		err = cfg.Validate()
+		for _, problem := range problems {
+			if err == nil || !strings.Contains(err.Error(), problem+" can only be used by the") {
+				t.Errorf("%s: %q is not reported in:\n%v", model, problem, err)
+			}
+		}
+		if errs, ok := err.(images.ConfigErrors); !ok || len(errs) != len(problems) {
+			t.Errorf("%s: %d problems are reported, but there should be %d:\n%v", model, len(errs), len(problems), err)
+		}
+	}
+	cfg = testConfig(images.ModelGray)
+	cfg.ColorBlend = 0.5
+	cfg.Scales[0].Color = "#ff8000"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "ColorBlend can only be used by the") {
+		t.Errorf("a gray ColorBlend should be reported, not %v", err)
+	}
+	cfg = testConfig(images.ModelGray)
+	cfg.ColorParams = json.RawMessage(`null`) // as in the effective config of every model
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("null ColorParams should not be reported, but: %v", err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(filename, []byte(`{"Modle": "rgb"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(filename); err == nil {
+		t.Errorf("an unknown field should be rejected")
+	}
+}
+
+func TestSetColorMap(t *testing.T) {
+	gray, err := New(testConfig(images.ModelGray))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gray.SetColorMap(images.ColorMap{Name: "inferno"}); err != nil {
+		t.Fatal(err)
+	}
+	if cm := gray.Config().ColorMap; cm.Name != "inferno" {
+		t.Errorf("the effective color map is %+v, but it should be inferno", cm)
+	}
+
+	rgb, err := New(testConfig(images.ModelRGB))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rgb.SetColorMap(images.ColorMap{Name: "inferno"}); err == nil {
+		t.Errorf("an rgb simulation should have no color map")
+	}
+}
@@ -0,0 +1,191 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"time"
+)
+
+// animated PNG, see: https://wiki.mozilla.org/APNG_Specification
+
+// APNGWriter streams the frames of an animated PNG to a file, so that only one frame is held in memory
+// NB: the file is written to a temporary file, renamed to the given filename on Close
+type APNGWriter struct {
+	filename string
+	file     *os.File
+	delay    time.Duration
+	loops    int
+	ihdr     []byte // the IHDR chunk data of the first frame, which every later frame must match
+	acTL     int64  // the offset of the acTL chunk, rewritten with the number of frames on Close
+	frames   int
+	sequence uint32 // the sequence number of the next fcTL or fdAT chunk
+}
+
+// CreateAPNG begin an animated PNG file
+// delay: how long each frame is shown
+// loops: the number of times the animation plays, or 0 to loop forever
+func CreateAPNG(filename string, delay time.Duration, loops int) (*APNGWriter, error) {
+	if loops < 0 {
+		return nil, fmt.Errorf("invalid APNG loop count %d", loops)
+	}
+	file, err := createTempFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &APNGWriter{filename: filename, file: file, delay: delay, loops: loops}, nil
+}
+
+// AddFrame append the given image to the animation
+// every frame must have the same size and PNG color type as the first
+func (a *APNGWriter) AddFrame(img image.Image) error {
+	encoded := bytes.Buffer{}
+	if err := png.Encode(&encoded, img); err != nil {
+		return err
+	}
+	ihdr, idat, err := pngImageData(encoded.Bytes())
+	if err != nil {
+		return err
+	}
+
+	if a.frames == 0 {
+		if err := a.writeHeader(ihdr); err != nil {
+			return err
+		}
+	} else if !bytes.Equal(ihdr, a.ihdr) {
+		return errors.New("every APNG frame must have the same size and color type as the first")
+	}
+
+	if err := a.writeFrameControl(); err != nil {
+		return err
+	}
+	// the first frame is the default image, written as IDAT, the others as fdAT
+	if a.frames == 0 {
+		err = writeChunk(a.file, "IDAT", idat)
+	} else {
+		err = writeChunk(a.file, "fdAT", append(a.nextSequenceNumber(), idat...))
+	}
+	if err != nil {
+		return err
+	}
+	a.frames++
+	return nil
+}
+
+// writeHeader write the PNG signature, the IHDR chunk and an acTL chunk
+func (a *APNGWriter) writeHeader(ihdr []byte) error {
+	a.ihdr = ihdr
+	if _, err := a.file.Write(pngSignature); err != nil {
+		return err
+	}
+	if err := writeChunk(a.file, "IHDR", ihdr); err != nil {
+		return err
+	}
+	offset, err := a.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	a.acTL = offset
+	return writeChunk(a.file, "acTL", a.animationControl())
+}
+
+// animationControl the acTL chunk data: the number of frames and loops
+func (a *APNGWriter) animationControl() []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint32(data[0:], uint32(a.frames))
+	binary.BigEndian.PutUint32(data[4:], uint32(a.loops))
+	return data
+}
+
+// writeFrameControl write the fcTL chunk of the next frame, which covers the whole image
+func (a *APNGWriter) writeFrameControl() error {
+	delayNumerator, delayDenominator := apngDelay(a.delay)
+	data := append(a.nextSequenceNumber(), make([]byte, 22)...)
+	copy(data[4:12], a.ihdr[0:8]) // width and height
+	// x and y offsets are 0
+	binary.BigEndian.PutUint16(data[20:], delayNumerator)
+	binary.BigEndian.PutUint16(data[22:], delayDenominator)
+	// dispose and blend ops are 0: APNG_DISPOSE_OP_NONE and APNG_BLEND_OP_SOURCE
+	return writeChunk(a.file, "fcTL", data)
+}
+
+func (a *APNGWriter) nextSequenceNumber() []byte {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, a.sequence)
+	a.sequence++
+	return data
+}
+
+// apngDelay the given delay as a fraction of a second, in milliseconds where possible
+func apngDelay(delay time.Duration) (uint16, uint16) {
+	ms := delay.Milliseconds()
+	switch {
+	case ms < 0:
+		return 0, 1000
+	case ms <= 0xffff:
+		return uint16(ms), 1000
+	default:
+		return uint16(Constrain(0, delay.Seconds(), 0xffff)), 1
+	}
+}
+
+// Close finish the animation, then rename it to its filename
+func (a *APNGWriter) Close() error {
+	tmpFilename := a.file.Name()
+	err := a.finish()
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFilename)
+		return err
+	}
+	return os.Rename(tmpFilename, a.filename)
+}
+
+// finish write the IEND chunk, then the number of frames into the acTL chunk
+func (a *APNGWriter) finish() error {
+	if a.frames == 0 {
+		return fmt.Errorf("%s: an APNG needs at least one frame", a.filename)
+	}
+	if err := writeChunk(a.file, "IEND", nil); err != nil {
+		return err
+	}
+	if _, err := a.file.Seek(a.acTL, io.SeekStart); err != nil {
+		return err
+	}
+	return writeChunk(a.file, "acTL", a.animationControl())
+}
+
+// pngImageData return the IHDR chunk data, and the concatenated data of every IDAT chunk, of the given PNG
+func pngImageData(contents []byte) ([]byte, []byte, error) {
+	if !bytes.HasPrefix(contents, pngSignature) {
+		return nil, nil, errors.New("not a PNG")
+	}
+	ihdr, idat := []byte(nil), []byte(nil)
+	for offset := len(pngSignature); offset+8 <= len(contents); {
+		length := int(binary.BigEndian.Uint32(contents[offset:]))
+		chunkType := string(contents[offset+4 : offset+8])
+		end := offset + 8 + length + 4
+		if end > len(contents) {
+			return nil, nil, fmt.Errorf("truncated PNG %s chunk", chunkType)
+		}
+		data := contents[offset+8 : offset+8+length]
+		switch chunkType {
+		case "IHDR":
+			ihdr = data
+		case "IDAT":
+			idat = append(idat, data...)
+		}
+		offset = end
+	}
+	if ihdr == nil || idat == nil {
+		return nil, nil, errors.New("PNG has no IHDR or IDAT chunk")
+	}
+	return ihdr, idat, nil
+}
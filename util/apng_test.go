@@ -0,0 +1,113 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// pngChunks return the type and data of every chunk in the given PNG
+func pngChunks(contents []byte) ([]string, map[string][][]byte) {
+	types, data := []string{}, map[string][][]byte{}
+	for offset := len(pngSignature); offset < len(contents); {
+		length := int(binary.BigEndian.Uint32(contents[offset:]))
+		chunkType := string(contents[offset+4 : offset+8])
+		types = append(types, chunkType)
+		data[chunkType] = append(data[chunkType], contents[offset+8:offset+8+length])
+		offset += 8 + length + 4
+	}
+	return types, data
+}
+
+func TestAPNG(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "animation.png")
+	apng, err := CreateAPNG(filename, 40*time.Millisecond, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		img := image.NewNRGBA(image.Rect(0, 0, 4, 3))
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 4; x++ {
+				img.Set(x, y, color.NRGBA{R: uint8(i * 100), G: uint8(x * 50), B: uint8(y * 50), A: 255})
+			}
+		}
+		if err := apng.AddFrame(img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := apng.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a decoder without APNG support should see the first frame
+	first, err := png.Decode(bytes.NewReader(contents))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _, _ := first.At(0, 0).RGBA(); r != 0 {
+		t.Errorf("the default image should be the first frame")
+	}
+
+	types, data := pngChunks(contents)
+	expected := []string{"IHDR", "acTL", "fcTL", "IDAT", "fcTL", "fdAT", "fcTL", "fdAT", "IEND"}
+	if !reflect.DeepEqual(types, expected) {
+		t.Errorf("chunks %v, but expected %v", types, expected)
+	}
+	if frames, loops := binary.BigEndian.Uint32(data["acTL"][0]), binary.BigEndian.Uint32(data["acTL"][0][4:]); frames != 3 || loops != 2 {
+		t.Errorf("acTL has %d frames and %d loops, but expected 3 and 2", frames, loops)
+	}
+	sequence := []uint32{}
+	for _, chunk := range append(data["fcTL"], data["fdAT"]...) {
+		sequence = append(sequence, binary.BigEndian.Uint32(chunk))
+	}
+	if !reflect.DeepEqual(sequence, []uint32{0, 1, 3, 2, 4}) {
+		t.Errorf("sequence numbers %v, but expected fcTL 0, 1, 3 and fdAT 2, 4", sequence)
+	}
+	if delay := binary.BigEndian.Uint16(data["fcTL"][0][20:]); delay != 40 {
+		t.Errorf("frame delay %dms, but expected 40ms", delay)
+	}
+}
+
+func TestAPNGFramesMustMatch(t *testing.T) {
+	apng, err := CreateAPNG(filepath.Join(t.TempDir(), "animation.png"), time.Second, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer apng.Close()
+
+	if err := apng.AddFrame(image.NewGray(image.Rect(0, 0, 4, 3))); err != nil {
+		t.Fatal(err)
+	}
+	if err := apng.AddFrame(image.NewGray(image.Rect(0, 0, 3, 4))); err == nil {
+		t.Errorf("a frame of a different size should be rejected")
+	}
+}
+
+func TestAPNGDelay(t *testing.T) {
+	tests := []struct {
+		delay    time.Duration
+		num, den uint16
+	}{
+		{-time.Second, 0, 1000},
+		{250 * time.Millisecond, 250, 1000},
+		{90 * time.Second, 90, 1},
+		{30 * time.Hour, 0xffff, 1},
+	}
+	for _, test := range tests {
+		if num, den := apngDelay(test.delay); num != test.num || den != test.den {
+			t.Errorf("the delay of %v is %d/%d, but it should be %d/%d", test.delay, num, den, test.num, test.den)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package util
+
+import (
+	"math"
+)
+
+// boundary modes, i.e. how pixels beyond the edges of a grid are sampled
+const (
+	BoundaryClip     = "clip"     // pixels beyond the edges are ignored
+	BoundaryWrap     = "wrap"     // the grid is toroidal, so pixels beyond one edge are taken from the opposite edge
+	BoundaryMirror   = "mirror"   // the grid is reflected at each edge
+	BoundaryConstant = "constant" // pixels beyond the edges all have the same constant value
+)
+
+// BoundaryIndex map the index i into the range [0, n) according to the given boundary
+// for BoundaryClip and BoundaryConstant the index is constrained to the nearest edge
+func BoundaryIndex(i, n int, boundary string) int {
+	switch boundary {
+	case BoundaryWrap:
+		return ((i % n) + n) % n
+	case BoundaryMirror:
+		period := 2 * n
+		i = ((i % period) + period) % period
+		if i >= n {
+			i = period - 1 - i
+		}
+		return i
+	default:
+		return ConstrainInt(0, i, n-1)
+	}
+}
+
+// PadGrid return a copy of the given grid, indexed [y][x], with pad extra pixels on every side
+// filled according to the given boundary, with value being the constant for BoundaryConstant
+// NB: for BoundaryClip the extra pixels are zero
+func PadGrid(grid [][]float64, pad int, boundary string, value float64) [][]float64 {
+	height := len(grid)
+	width := len(grid[0])
+	padded := Make2DGridFloat64(width+2*pad, height+2*pad)
+
+	for y := range padded {
+		gy := y - pad
+		for x := range padded[y] {
+			gx := x - pad
+			switch {
+			case 0 <= gx && gx < width && 0 <= gy && gy < height:
+				padded[y][x] = grid[gy][gx]
+			case boundary == BoundaryClip:
+				padded[y][x] = 0.0
+			case boundary == BoundaryConstant:
+				padded[y][x] = value
+			default:
+				padded[y][x] = grid[BoundaryIndex(gy, height, boundary)][BoundaryIndex(gx, width, boundary)]
+			}
+		}
+	}
+	return padded
+}
+
+// TileSeamRatio compare the opposite edges of the given grid with its interior
+// i.e. the mean difference across the seams where the grid meets a copy of itself
+// (last column to first column, last row to first row) divided by the mean
+// difference between neighbouring pixels within the grid
+// a grid which tiles seamlessly has a ratio close to 1, or below
+func TileSeamRatio(grid [][]float64) float64 {
+	height := len(grid)
+	width := len(grid[0])
+
+	seam := 0.0
+	for y := 0; y < height; y++ {
+		seam += math.Abs(grid[y][width-1] - grid[y][0])
+	}
+	for x := 0; x < width; x++ {
+		seam += math.Abs(grid[height-1][x] - grid[0][x])
+	}
+	seam /= float64(width + height)
+
+	interior, count := 0.0, 0
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if x+1 < width {
+				interior += math.Abs(grid[y][x+1] - grid[y][x])
+				count++
+			}
+			if y+1 < height {
+				interior += math.Abs(grid[y+1][x] - grid[y][x])
+				count++
+			}
+		}
+	}
+	interior /= float64(count)
+
+	if interior == 0.0 {
+		if seam == 0.0 {
+			return 1.0
+		}
+		return math.Inf(1)
+	}
+	return seam / interior
+}
+
+// IsTileable does the given grid tile seamlessly, i.e. are its opposite edges
+// no more different than neighbouring pixels, within the given tolerance?
+func IsTileable(grid [][]float64, tolerance float64) bool {
+	return TileSeamRatio(grid) <= 1.0+tolerance
+}
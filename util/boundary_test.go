@@ -0,0 +1,81 @@
+package util
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBoundaryIndex(t *testing.T) {
+	tests := []struct {
+		boundary    string
+		i, n, index int
+	}{
+		{BoundaryClip, -3, 5, 0},
+		{BoundaryClip, 7, 5, 4},
+		{BoundaryConstant, -1, 5, 0},
+		{BoundaryWrap, -1, 5, 4},
+		{BoundaryWrap, 5, 5, 0},
+		{BoundaryWrap, 12, 5, 2},
+		{BoundaryMirror, -1, 5, 0},
+		{BoundaryMirror, -2, 5, 1},
+		{BoundaryMirror, 5, 5, 4},
+		{BoundaryMirror, 6, 5, 3},
+		{BoundaryMirror, 11, 5, 1},
+	}
+	for _, test := range tests {
+		if index := BoundaryIndex(test.i, test.n, test.boundary); index != test.index {
+			t.Errorf("BoundaryIndex(%d, %d, %s) is %d, but it should be %d", test.i, test.n, test.boundary, index, test.index)
+		}
+	}
+}
+
+func TestPadGrid(t *testing.T) {
+	grid := [][]float64{
+		{1, 2, 3},
+		{4, 5, 6},
+	}
+	tests := map[string][]float64{ // the top row of the grid padded by 1
+		BoundaryClip:     {0, 0, 0, 0, 0},
+		BoundaryConstant: {9, 9, 9, 9, 9},
+		BoundaryWrap:     {6, 4, 5, 6, 4},
+		BoundaryMirror:   {1, 1, 2, 3, 3},
+	}
+	for boundary, topRow := range tests {
+		padded := PadGrid(grid, 1, boundary, 9)
+		if len(padded) != 4 || len(padded[0]) != 5 {
+			t.Fatalf("%s padded grid is %dx%d, but it should be 5x4", boundary, len(padded[0]), len(padded))
+		}
+		for x, value := range topRow {
+			if padded[0][x] != value {
+				t.Errorf("%s padded grid[0][%d] is %v, but it should be %v", boundary, x, padded[0][x], value)
+			}
+		}
+		if padded[2][3] != 6 {
+			t.Errorf("%s padded grid[2][3] is %v, but it should be %v", boundary, padded[2][3], 6)
+		}
+	}
+}
+
+func TestTileSeamRatio(t *testing.T) {
+	// a ramp has a large jump from its last column back to its first
+	ramp := Make2DGridFloat64(10, 10)
+	for y := range ramp {
+		for x := range ramp[y] {
+			ramp[y][x] = float64(x)
+		}
+	}
+	if IsTileable(ramp, 0.5) {
+		t.Errorf("a ramp should not be tileable, its seam ratio is %v", TileSeamRatio(ramp))
+	}
+
+	// whereas a triangle wave rises and falls back to its start
+	triangle := Make2DGridFloat64(10, 10)
+	for y := range triangle {
+		for x := range triangle[y] {
+			triangle[y][x] = math.Min(float64(x), float64(10-x))
+		}
+	}
+	if !IsTileable(triangle, 0.5) {
+		t.Errorf("a triangle wave should be tileable, its seam ratio is %v", TileSeamRatio(triangle))
+	}
+}
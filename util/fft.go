@@ -0,0 +1,112 @@
+package util
+
+import (
+	"math"
+	"math/bits"
+	"math/cmplx"
+	"sync"
+)
+
+// NextPowerOf2 return the smallest power of 2 which is >= n
+func NextPowerOf2(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << uint(bits.Len(uint(n-1)))
+}
+
+// Make2DGridComplex128 make a 2D array of complex128
+func Make2DGridComplex128(width, height int) [][]complex128 {
+	grid := make([][]complex128, height)
+	for i := range grid {
+		grid[i] = make([]complex128, width)
+	}
+	return grid
+}
+
+// FFT in-place fast fourier transform of the given values, whose length must be a power of 2
+// the inverse transform is scaled by 1/n, so that FFT(FFT(v, false), true) == v
+// see: https://en.wikipedia.org/wiki/Cooley%E2%80%93Tukey_FFT_algorithm
+func FFT(values []complex128, inverse bool) {
+	n := len(values)
+	if n <= 1 {
+		return
+	}
+
+	// bit reversal permutation
+	shift := uint(64 - bits.Len(uint(n-1)))
+	for i := 0; i < n; i++ {
+		j := int(bits.Reverse64(uint64(i)) >> shift)
+		if i < j {
+			values[i], values[j] = values[j], values[i]
+		}
+	}
+
+	sign := -1.0
+	if inverse {
+		sign = 1.0
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Rect(1, sign*2*math.Pi/float64(size))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even, odd := values[start+k], w*values[start+k+size/2]
+				values[start+k] = even + odd
+				values[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+
+	if inverse {
+		scale := complex(1/float64(n), 0)
+		for i := range values {
+			values[i] *= scale
+		}
+	}
+}
+
+// FFT2D in-place 2D fast fourier transform of the given grid, indexed [y][x]
+// both its width and height must be powers of 2
+// the rows, then the columns, are transformed concurrently by the given number of workers
+func FFT2D(grid [][]complex128, inverse bool, workers int) {
+	height := len(grid)
+	width := len(grid[0])
+
+	InParallel(height, workers, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			FFT(grid[y], inverse)
+		}
+	})
+
+	InParallel(width, workers, func(x0, x1 int) {
+		column := make([]complex128, height)
+		for x := x0; x < x1; x++ {
+			for y := 0; y < height; y++ {
+				column[y] = grid[y][x]
+			}
+			FFT(column, inverse)
+			for y := 0; y < height; y++ {
+				grid[y][x] = column[y]
+			}
+		}
+	})
+}
+
+// InParallel split [0, n) into one range per worker and call fn concurrently for each range [i0, i1)
+func InParallel(n, workers int, fn func(i0, i1 int)) {
+	workers = ConstrainInt(1, workers, n)
+	size := (n + workers - 1) / workers
+
+	var wg sync.WaitGroup
+	for i0 := 0; i0 < n; i0 += size {
+		i1 := ConstrainInt(i0, i0+size, n)
+		wg.Add(1)
+		go func(i0, i1 int) {
+			defer wg.Done()
+			fn(i0, i1)
+		}(i0, i1)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,76 @@
+package util
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+// naiveDFT the O(n²) reference for FFT
+func naiveDFT(values []complex128) []complex128 {
+	n := len(values)
+	result := make([]complex128, n)
+	for k := 0; k < n; k++ {
+		for t := 0; t < n; t++ {
+			result[k] += values[t] * cmplx.Rect(1, -2*math.Pi*float64(k*t)/float64(n))
+		}
+	}
+	return result
+}
+
+func TestNextPowerOf2(t *testing.T) {
+	for n, expected := range map[int]int{0: 1, 1: 1, 2: 2, 3: 4, 600: 1024, 1024: 1024, 1025: 2048} {
+		if NextPowerOf2(n) != expected {
+			t.Errorf("NextPowerOf2(%d) is %d, but it should be %d", n, NextPowerOf2(n), expected)
+		}
+	}
+}
+
+func TestFFTAgreesWithDFT(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	values := make([]complex128, 64)
+	for i := range values {
+		values[i] = complex(RandFloat64(rng, -1.0, 1.0), RandFloat64(rng, -1.0, 1.0))
+	}
+	expected := naiveDFT(values)
+
+	transformed := append([]complex128(nil), values...)
+	FFT(transformed, false)
+	for i := range expected {
+		if cmplx.Abs(transformed[i]-expected[i]) > 1e-9 {
+			t.Errorf("FFT()[%d] is %v, but it should be %v", i, transformed[i], expected[i])
+		}
+	}
+
+	FFT(transformed, true)
+	for i := range values {
+		if cmplx.Abs(transformed[i]-values[i]) > 1e-9 {
+			t.Errorf("inverse FFT()[%d] is %v, but it should be %v", i, transformed[i], values[i])
+		}
+	}
+}
+
+func TestFFT2DRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	grid := Make2DGridComplex128(16, 8)
+	for y := range grid {
+		for x := range grid[y] {
+			grid[y][x] = complex(RandFloat64(rng, -1.0, 1.0), 0)
+		}
+	}
+	original := Make2DGridComplex128(16, 8)
+	for y := range grid {
+		copy(original[y], grid[y])
+	}
+
+	FFT2D(grid, false, 3)
+	FFT2D(grid, true, 3)
+	for y := range grid {
+		for x := range grid[y] {
+			if cmplx.Abs(grid[y][x]-original[y][x]) > 1e-9 {
+				t.Errorf("grid[%d][%d] is %v after a round trip, but it should be %v", y, x, grid[y][x], original[y][x])
+			}
+		}
+	}
+}
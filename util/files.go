@@ -1,36 +1,78 @@
 package util
 
 import (
+	"bufio"
 	"image"
 	"image/color"
-	"image/png"
-	"log"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
 // OutputPNG export this image as a PNG
-func OutputPNG(filename string, pixmap [][]color.NRGBA) {
+// pixmap: the pixels of the image, indexed [y][x]
+// text: keyword/value pairs written to the PNG's text chunks, see EncodePNG
+// NB: the file is written atomically, so an interrupted run never leaves a partial PNG
+func OutputPNG(filename string, pixmap [][]color.NRGBA, text map[string]string) error {
+	img := PixmapImage(pixmap)
+	return WriteFileAtomically(filename, func(w io.Writer) error {
+		return EncodePNG(w, img, text)
+	})
+}
+
+// PixmapImage return the given pixels, indexed [y][x], as an image
+func PixmapImage(pixmap [][]color.NRGBA) *image.NRGBA {
 	height := len(pixmap)
 	width := len(pixmap[0])
 	img := image.NewNRGBA(image.Rect(0, 0, width, height))
 
 	for y := 0; y < height; y++ {
 		for x := 0; x < width; x++ {
-			img.Set(x, y, pixmap[x][y])
+			img.SetNRGBA(x, y, pixmap[y][x])
 		}
 	}
+	return img
+}
 
-	f, err := os.Create(filename)
+// WriteFileAtomically call write to fill a temporary file, in the same directory as the given file,
+// then rename it to the given filename; either the whole file is written, or it is left unchanged
+func WriteFileAtomically(filename string, write func(w io.Writer) error) error {
+	f, err := createTempFile(filename)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	tmpFilename := f.Name()
 
-	if err := png.Encode(f, img); err != nil {
+	w := bufio.NewWriter(f)
+	if err := write(w); err != nil {
 		f.Close()
-		log.Fatal(err)
+		os.Remove(tmpFilename)
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmpFilename)
+		return err
 	}
-
 	if err := f.Close(); err != nil {
-		log.Fatal(err)
+		os.Remove(tmpFilename)
+		return err
+	}
+	return os.Rename(tmpFilename, filename)
+}
+
+// createTempFile create a temporary file in the same directory as the given file, to be renamed to it once complete
+func createTempFile(filename string) (*os.File, error) {
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	// TempFile creates the file readable only by its owner
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
 	}
+	return f, nil
 }
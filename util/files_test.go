@@ -0,0 +1,38 @@
+package util
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomically(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "file.txt")
+
+	err := WriteFileAtomically(filename, func(w io.Writer) error {
+		_, err := io.WriteString(w, "complete")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = WriteFileAtomically(filename, func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return errors.New("interrupted")
+	})
+	if err == nil {
+		t.Errorf("a failed write should return its error")
+	}
+
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil || string(contents) != "complete" {
+		t.Errorf("file contains %q (%v), but it should still contain %q", contents, err, "complete")
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("a failed write should remove its temporary file, but there are %d files", len(files))
+	}
+}
@@ -20,22 +20,23 @@ func PointIsWithinCircle(xp, yp, x, y, radius int) bool {
 	return sqr(radius) >= sqr(xp-x)+sqr(yp-y)
 }
 
-// AverageOfPixelsWithinCircle return average of all pixel values in the given circle
+// AverageOfPixelsWithinCircle return the average of all pixel values within (or on) the given circle, and the image bounds
+// NB: this is the O(radius²) reference for RowPrefixSums.AverageOfPixelsWithinCircle
 func AverageOfPixelsWithinCircle(x, y, radius int, grid [][]float64) float64 {
 	// x, y, radius: the circle of values from which to derive an average
-	// grid: the grid of values from which the circles are found
+	// grid: the grid of values from which the circles are found, indexed [y][x]
 	sum := 0.0
-	numPixelsWithinCircle := 1.0
+	numPixelsWithinCircle := 0.0
 
-	for i := x - radius; i < x+radius; i++ {
-		for j := y - radius; j < y+radius; j++ {
+	for i := x - radius; i <= x+radius; i++ {
+		for j := y - radius; j <= y+radius; j++ {
 
 			// only include pixel values within the image bounds
 			if (i >= 0) && (i < len(grid[0])) &&
 				(j >= 0) && (j < len(grid)) {
 
 				if PointIsWithinCircle(i, j, x, y, radius) {
-					sum += grid[i][j]
+					sum += grid[j][i]
 					numPixelsWithinCircle++
 				}
 			}
@@ -0,0 +1,373 @@
+package util
+
+import (
+	"bufio"
+	"compress/lzw"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// animated GIF, see: https://www.w3.org/Graphics/GIF/spec-gif89a.txt
+
+// GIF palettes
+const (
+	PaletteFrame  = "frame"  // quantise each frame to its own palette
+	PaletteGlobal = "global" // quantise every frame to one palette, built from the colors of all of them
+)
+
+// GIFOptions how an animated GIF is written
+type GIFOptions struct {
+	Delay     time.Duration // how long each frame is shown, in steps of 10ms
+	Loops     int           // the number of times the animation plays, or 0 to loop forever
+	Palette   string        // PaletteFrame (default) or PaletteGlobal
+	Quantizer string        // QuantizeMedianCut (default) or QuantizeOctree
+	Dither    bool          // Floyd-Steinberg error diffusion
+}
+
+// gifColors the maximum size of a GIF palette
+const gifColors = 256
+
+// GIFWriter streams the frames of an animated GIF to a file
+// with a global palette, frames are spooled to a temporary file until the palette is known on Close
+// NB: the file is written to a temporary file, renamed to the given filename on Close
+type GIFWriter struct {
+	filename string
+	file     *os.File
+	w        *bufio.Writer
+	options  GIFOptions
+	bounds   image.Rectangle // of the first frame, which every later frame must match
+	frames   int
+
+	histogram ColorHistogram // of every frame, for a global palette
+	spool     *os.File       // the raw RGB pixels of every frame, for a global palette
+	spoolW    *bufio.Writer
+}
+
+// CreateGIF begin an animated GIF file
+func CreateGIF(filename string, options GIFOptions) (*GIFWriter, error) {
+	switch options.Palette {
+	case "":
+		options.Palette = PaletteFrame
+	case PaletteFrame, PaletteGlobal:
+	default:
+		return nil, fmt.Errorf("unknown GIF palette %q, expected %q or %q", options.Palette, PaletteFrame, PaletteGlobal)
+	}
+	switch options.Quantizer {
+	case "":
+		options.Quantizer = QuantizeMedianCut
+	case QuantizeMedianCut, QuantizeOctree:
+	default:
+		return nil, fmt.Errorf("unknown quantisation method %q, expected %q or %q", options.Quantizer, QuantizeMedianCut, QuantizeOctree)
+	}
+	if options.Loops < 0 {
+		return nil, fmt.Errorf("invalid GIF loop count %d", options.Loops)
+	}
+
+	file, err := createTempFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	g := &GIFWriter{filename: filename, file: file, w: bufio.NewWriter(file), options: options}
+	if options.Palette == PaletteGlobal {
+		if g.spool, err = ioutil.TempFile("", "gif-frames.*.tmp"); err != nil {
+			file.Close()
+			os.Remove(file.Name())
+			return nil, err
+		}
+		g.spoolW = bufio.NewWriter(g.spool)
+		g.histogram = ColorHistogram{}
+	}
+	return g, nil
+}
+
+// AddFrame append the given image to the animation
+// every frame must have the same size as the first
+func (g *GIFWriter) AddFrame(img image.Image) error {
+	if g.frames == 0 {
+		g.bounds = img.Bounds()
+		if g.options.Palette == PaletteFrame {
+			if err := g.writeHeader(nil); err != nil {
+				return err
+			}
+		}
+	} else if img.Bounds().Size() != g.bounds.Size() {
+		return errors.New("every GIF frame must have the same size as the first")
+	}
+	g.frames++
+
+	if g.options.Palette == PaletteGlobal {
+		g.histogram.Add(img)
+		return spoolFrame(g.spoolW, img)
+	}
+
+	histogram := ColorHistogram{}
+	histogram.Add(img)
+	palette, err := Quantize(histogram, gifColors, g.options.Quantizer)
+	if err != nil {
+		return err
+	}
+	return g.writeFrame(g.paletted(img, palette), true)
+}
+
+// paletted return the given image using the given palette
+func (g *GIFWriter) paletted(img image.Image, palette color.Palette) *image.Paletted {
+	bounds := img.Bounds()
+	dst := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), palette)
+	if g.options.Dither {
+		draw.FloydSteinberg.Draw(dst, dst.Bounds(), img, bounds.Min)
+		return dst
+	}
+
+	// many pixels share a color, so remember the nearest palette entry of each
+	nearest := map[rgb]uint8{}
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			c := rgbOf(img.At(bounds.Min.X+x, bounds.Min.Y+y))
+			ndx, ok := nearest[c]
+			if !ok {
+				ndx = uint8(palette.Index(color.NRGBA{R: c[0], G: c[1], B: c[2], A: 255}))
+				nearest[c] = ndx
+			}
+			dst.Pix[y*dst.Stride+x] = ndx
+		}
+	}
+	return dst
+}
+
+// spoolFrame write the raw RGB pixels of the given image
+func spoolFrame(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	row := make([]byte, 3*bounds.Dx())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			c := rgbOf(img.At(bounds.Min.X+x, y))
+			copy(row[3*x:], c[:])
+		}
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unspoolFrame read the raw RGB pixels of the next spooled frame
+func (g *GIFWriter) unspoolFrame(r io.Reader) (*image.NRGBA, error) {
+	width, height := g.bounds.Dx(), g.bounds.Dy()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	row := make([]byte, 3*width)
+	for y := 0; y < height; y++ {
+		if _, err := io.ReadFull(r, row); err != nil {
+			return nil, err
+		}
+		for x := 0; x < width; x++ {
+			copy(img.Pix[y*img.Stride+4*x:], row[3*x:3*x+3])
+			img.Pix[y*img.Stride+4*x+3] = 255
+		}
+	}
+	return img, nil
+}
+
+// paletteBits the number of bits needed to index the given palette
+func paletteBits(palette color.Palette) int {
+	bits := 1
+	for 1<<uint(bits) < len(palette) {
+		bits++
+	}
+	return bits
+}
+
+// writeColorTable write the given palette, padded to a power of 2 colors
+func (g *GIFWriter) writeColorTable(palette color.Palette) error {
+	table := make([]byte, 3<<uint(paletteBits(palette)))
+	for i, c := range palette {
+		rgb := rgbOf(c)
+		copy(table[3*i:], rgb[:])
+	}
+	_, err := g.w.Write(table)
+	return err
+}
+
+// writeHeader write the GIF header, logical screen descriptor, global color table (if any) and loop count
+func (g *GIFWriter) writeHeader(global color.Palette) error {
+	header := make([]byte, 13)
+	copy(header, "GIF89a")
+	binary.LittleEndian.PutUint16(header[6:], uint16(g.bounds.Dx()))
+	binary.LittleEndian.PutUint16(header[8:], uint16(g.bounds.Dy()))
+	if global != nil {
+		header[10] = 0x80 | 0x70 | byte(paletteBits(global)-1)
+	}
+	if _, err := g.w.Write(header); err != nil {
+		return err
+	}
+	if global != nil {
+		if err := g.writeColorTable(global); err != nil {
+			return err
+		}
+	}
+
+	// the NETSCAPE2.0 extension counts the repeats after the first play, 0 repeats forever
+	if g.options.Loops == 1 {
+		return nil
+	}
+	repeats := 0
+	if g.options.Loops > 1 {
+		repeats = g.options.Loops - 1
+	}
+	extension := []byte("\x21\xff\x0bNETSCAPE2.0\x03\x01\x00\x00\x00")
+	binary.LittleEndian.PutUint16(extension[16:], uint16(ConstrainInt(0, repeats, 0xffff)))
+	_, err := g.w.Write(extension)
+	return err
+}
+
+// writeFrame write the graphic control extension, image descriptor and LZW compressed pixels of a frame
+func (g *GIFWriter) writeFrame(img *image.Paletted, localPalette bool) error {
+	control := []byte{0x21, 0xf9, 0x04, 0, 0, 0, 0, 0}
+	delay := ConstrainInt(0, int(g.options.Delay/(10*time.Millisecond)), 0xffff)
+	binary.LittleEndian.PutUint16(control[4:], uint16(delay))
+	if _, err := g.w.Write(control); err != nil {
+		return err
+	}
+
+	descriptor := make([]byte, 10)
+	descriptor[0] = 0x2c
+	binary.LittleEndian.PutUint16(descriptor[5:], uint16(img.Rect.Dx()))
+	binary.LittleEndian.PutUint16(descriptor[7:], uint16(img.Rect.Dy()))
+	if localPalette {
+		descriptor[9] = 0x80 | byte(paletteBits(img.Palette)-1)
+	}
+	if _, err := g.w.Write(descriptor); err != nil {
+		return err
+	}
+	if localPalette {
+		if err := g.writeColorTable(img.Palette); err != nil {
+			return err
+		}
+	}
+
+	litWidth := paletteBits(img.Palette)
+	if litWidth < 2 {
+		litWidth = 2
+	}
+	if err := g.w.WriteByte(byte(litWidth)); err != nil {
+		return err
+	}
+	blocks := &gifBlockWriter{w: g.w}
+	compressor := lzw.NewWriter(blocks, lzw.LSB, litWidth)
+	if _, err := compressor.Write(img.Pix); err != nil {
+		return err
+	}
+	if err := compressor.Close(); err != nil {
+		return err
+	}
+	return blocks.close()
+}
+
+// gifBlockWriter splits the LZW compressed pixels into sub-blocks of up to 255 bytes
+type gifBlockWriter struct {
+	w     io.Writer
+	block [256]byte
+	n     int
+}
+
+func (b *gifBlockWriter) Write(data []byte) (int, error) {
+	for i, d := range data {
+		b.n++
+		b.block[b.n] = d
+		if b.n == 255 {
+			if err := b.flush(); err != nil {
+				return i, err
+			}
+		}
+	}
+	return len(data), nil
+}
+
+func (b *gifBlockWriter) flush() error {
+	if b.n == 0 {
+		return nil
+	}
+	b.block[0] = byte(b.n)
+	_, err := b.w.Write(b.block[:b.n+1])
+	b.n = 0
+	return err
+}
+
+// close write the last sub-block, then the block terminator
+func (b *gifBlockWriter) close() error {
+	if err := b.flush(); err != nil {
+		return err
+	}
+	_, err := b.w.Write([]byte{0})
+	return err
+}
+
+// Close finish the animation, then rename it to its filename
+func (g *GIFWriter) Close() error {
+	tmpFilename := g.file.Name()
+	err := g.finish()
+	if g.spool != nil {
+		g.spool.Close()
+		os.Remove(g.spool.Name())
+	}
+	if closeErr := g.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFilename)
+		return err
+	}
+	return os.Rename(tmpFilename, g.filename)
+}
+
+// finish write the spooled frames (for a global palette), then the trailer
+func (g *GIFWriter) finish() error {
+	if g.frames == 0 {
+		return fmt.Errorf("%s: a GIF needs at least one frame", g.filename)
+	}
+	if g.options.Palette == PaletteGlobal {
+		if err := g.writeSpooledFrames(); err != nil {
+			return err
+		}
+	}
+	if err := g.w.WriteByte(0x3b); err != nil {
+		return err
+	}
+	return g.w.Flush()
+}
+
+// writeSpooledFrames quantise every spooled frame to one palette, built from the colors of all of them
+func (g *GIFWriter) writeSpooledFrames() error {
+	palette, err := Quantize(g.histogram, gifColors, g.options.Quantizer)
+	if err != nil {
+		return err
+	}
+	if err := g.writeHeader(palette); err != nil {
+		return err
+	}
+
+	if err := g.spoolW.Flush(); err != nil {
+		return err
+	}
+	if _, err := g.spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(g.spool)
+	for i := 0; i < g.frames; i++ {
+		img, err := g.unspoolFrame(r)
+		if err != nil {
+			return err
+		}
+		if err := g.writeFrame(g.paletted(img, palette), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
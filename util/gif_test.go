@@ -0,0 +1,83 @@
+package util
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGIF(t *testing.T) {
+	frames := []*image.NRGBA{gradientImage(40, 30), gradientImage(40, 30), gradientImage(40, 30)}
+	frames[1].SetNRGBA(5, 5, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+	frames[2].SetNRGBA(0, 0, color.NRGBA{R: 255, G: 0, B: 255, A: 255})
+
+	for _, options := range []GIFOptions{
+		{Delay: 50 * time.Millisecond, Loops: 0, Palette: PaletteFrame, Quantizer: QuantizeMedianCut},
+		{Delay: 200 * time.Millisecond, Loops: 3, Palette: PaletteGlobal, Quantizer: QuantizeOctree, Dither: true},
+		{Delay: 10 * time.Millisecond, Loops: 1, Palette: PaletteGlobal, Quantizer: QuantizeMedianCut},
+	} {
+		filename := filepath.Join(t.TempDir(), "animation.gif")
+		w, err := CreateGIF(filename, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, frame := range frames {
+			if err := w.AddFrame(frame); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := os.Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := gif.DecodeAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%+v: %v", options, err)
+		}
+
+		if len(decoded.Image) != len(frames) {
+			t.Errorf("%+v: %d frames, but expected %d", options, len(decoded.Image), len(frames))
+			continue
+		}
+		// Go's LoopCount counts the repeats after the first play, 0 loops forever and -1 plays once
+		expected := map[int]int{0: 0, 1: -1, 3: 2}[options.Loops]
+		if decoded.LoopCount != expected {
+			t.Errorf("%+v: loop count %d, but expected %d", options, decoded.LoopCount, expected)
+		}
+		for i, frame := range frames {
+			if decoded.Delay[i] != int(options.Delay/(10*time.Millisecond)) {
+				t.Errorf("%+v: delay %d, but expected %v", options, decoded.Delay[i], options.Delay)
+			}
+			if d := colorDistance(decoded.Image[i].At(20, 15), frame.At(20, 15)); d > 24*24 {
+				t.Errorf("%+v: frame %d is %v, but expected about %v", options, i, decoded.Image[i].At(20, 15), frame.At(20, 15))
+			}
+		}
+		if options.Palette == PaletteGlobal && len(decoded.Config.ColorModel.(color.Palette)) == 0 {
+			t.Errorf("%+v: expected a global palette", options)
+		}
+	}
+}
+
+func TestGIFFramesMustMatch(t *testing.T) {
+	w, err := CreateGIF(filepath.Join(t.TempDir(), "animation.gif"), GIFOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err := w.AddFrame(gradientImage(4, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddFrame(gradientImage(3, 4)); err == nil {
+		t.Errorf("a frame of a different size should be rejected")
+	}
+}
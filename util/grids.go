@@ -2,6 +2,7 @@ package util
 
 import (
 	"image/color"
+	"math/rand"
 
 	"github.com/dhodges/turing_patterns/hsb"
 )
@@ -15,6 +16,15 @@ func Make2DGridFloat64(width, height int) [][]float64 {
 	return grid
 }
 
+// Make2DGridInt make a 2D array of int
+func Make2DGridInt(width, height int) [][]int {
+	grid := make([][]int, height)
+	for i := range grid {
+		grid[i] = make([]int, width)
+	}
+	return grid
+}
+
 // Make2DGridUInt8 make a 2D array of uint8
 func Make2DGridUInt8(width, height int) [][]uint8 {
 	grid := make([][]uint8, height)
@@ -43,11 +53,11 @@ func Make2DGridNHSBA(width, height int) [][]hsb.NHSBA {
 }
 
 // Make2DGridFloat64Randomised make a 2D array of random float64 values
-func Make2DGridFloat64Randomised(width, height int) [][]float64 {
+func Make2DGridFloat64Randomised(rng *rand.Rand, width, height int) [][]float64 {
 	grid := Make2DGridFloat64(width, height)
 	for i := 0; i < height; i++ {
 		for j := 0; j < width; j++ {
-			grid[i][j] = RandFloat64(-1.0, 1.0)
+			grid[i][j] = RandFloat64(rng, -1.0, 1.0)
 		}
 	}
 	return grid
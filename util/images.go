@@ -0,0 +1,64 @@
+package util
+
+import (
+	"image"
+	_ "image/gif"  // register the GIF decoder for LoadImage
+	_ "image/jpeg" // register the JPEG decoder for LoadImage
+	_ "image/png"  // register the PNG decoder for LoadImage
+	"math"
+	"os"
+)
+
+// LoadImage decode the PNG, JPEG or GIF image in the given file
+func LoadImage(filename string) (image.Image, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// LuminanceGrid return the luminance of every pixel of the given image, indexed [y][x]
+// between 0.0 (black) and 1.0 (white), using the Rec. 709 luma coefficients
+// see: https://en.wikipedia.org/wiki/Luma_(video)
+func LuminanceGrid(img image.Image) [][]float64 {
+	bounds := img.Bounds()
+	grid := Make2DGridFloat64(bounds.Dx(), bounds.Dy())
+	for y := range grid {
+		for x := range grid[y] {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			grid[y][x] = (0.2126*float64(r) + 0.7152*float64(g) + 0.0722*float64(b)) / 0xffff
+		}
+	}
+	return grid
+}
+
+// ResampleGrid return a copy of the given grid resized to width x height, by bilinear interpolation
+func ResampleGrid(grid [][]float64, width, height int) [][]float64 {
+	srcHeight := len(grid)
+	srcWidth := len(grid[0])
+	resampled := Make2DGridFloat64(width, height)
+
+	for y := 0; y < height; y++ {
+		// map the centre of each pixel back into the source grid
+		sy := Constrain(0, (float64(y)+0.5)*float64(srcHeight)/float64(height)-0.5, float64(srcHeight-1))
+		y0 := int(math.Floor(sy))
+		y1 := ConstrainInt(0, y0+1, srcHeight-1)
+		fy := sy - float64(y0)
+
+		for x := 0; x < width; x++ {
+			sx := Constrain(0, (float64(x)+0.5)*float64(srcWidth)/float64(width)-0.5, float64(srcWidth-1))
+			x0 := int(math.Floor(sx))
+			x1 := ConstrainInt(0, x0+1, srcWidth-1)
+			fx := sx - float64(x0)
+
+			top := grid[y0][x0]*(1-fx) + grid[y0][x1]*fx
+			bottom := grid[y1][x0]*(1-fx) + grid[y1][x1]*fx
+			resampled[y][x] = top*(1-fy) + bottom*fy
+		}
+	}
+	return resampled
+}
@@ -2,6 +2,8 @@ package util
 
 import (
 	"image"
+	"image/color"
+	"math"
 	"testing"
 )
 
@@ -76,9 +78,52 @@ func TestAverageOfPixelsWithinCircle(t *testing.T) {
 			grid[x][y] = 1.0
 		}
 	}
-	expected := 0.55
+	expected := 44.0 / 81 // 44 of the 81 pixels within the circle are 1.0
 	average := AverageOfPixelsWithinCircle(5, 5, 5, grid)
 	if average != expected {
 		t.Errorf("average of circle(x:%d, y:%d, radius:%d) is %.2f, should be %.2f", 5, 5, 5, average, expected)
 	}
 }
+
+func TestLuminanceGrid(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.NRGBA{0, 0, 0, 255})
+	img.Set(1, 0, color.NRGBA{255, 255, 255, 255})
+
+	grid := LuminanceGrid(img)
+	if len(grid) != 1 || len(grid[0]) != 2 {
+		t.Fatalf("luminance grid is %dx%d, but it should be 2x1", len(grid[0]), len(grid))
+	}
+	if grid[0][0] != 0.0 {
+		t.Errorf("luminance of black is %v, but it should be %v", grid[0][0], 0.0)
+	}
+	if math.Abs(grid[0][1]-1.0) > 1e-9 {
+		t.Errorf("luminance of white is %v, but it should be %v", grid[0][1], 1.0)
+	}
+}
+
+func TestResampleGrid(t *testing.T) {
+	grid := [][]float64{
+		{0, 1},
+		{2, 3},
+	}
+	same := ResampleGrid(grid, 2, 2)
+	for y := range grid {
+		for x := range grid[y] {
+			if same[y][x] != grid[y][x] {
+				t.Errorf("resampling to the same size changed grid[%d][%d] to %v, but it should be %v", y, x, same[y][x], grid[y][x])
+			}
+		}
+	}
+
+	larger := ResampleGrid(grid, 4, 3)
+	if len(larger) != 3 || len(larger[0]) != 4 {
+		t.Fatalf("resampled grid is %dx%d, but it should be 4x3", len(larger[0]), len(larger))
+	}
+	if larger[0][0] != 0 || larger[2][3] != 3 {
+		t.Errorf("resampling should keep the corners, but they are %v and %v", larger[0][0], larger[2][3])
+	}
+	if larger[1][1] <= larger[1][0] || larger[1][2] <= larger[1][1] {
+		t.Errorf("resampling should interpolate between pixels, but row 1 is %v", larger[1])
+	}
+}
@@ -33,6 +33,6 @@ func ConstrainInt(min, n, max int) int {
 }
 
 // RandFloat64 generate a random float64 between the given min and max
-func RandFloat64(min, max float64) float64 {
-	return min + rand.Float64()*(max-min)
+func RandFloat64(rng *rand.Rand, min, max float64) float64 {
+	return min + rng.Float64()*(max-min)
 }
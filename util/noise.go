@@ -0,0 +1,123 @@
+package util
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Noise coherent 2D noise functions, each returning values in (about) [-1, +1]
+// all three share a permutation table drawn from a random number generator,
+// so the same seed always gives the same noise
+type Noise struct {
+	perm [512]int
+}
+
+// NewNoise return Noise whose permutation table is drawn from the given random number generator
+func NewNoise(rng *rand.Rand) *Noise {
+	noise := &Noise{}
+	for i, p := range rng.Perm(256) {
+		noise.perm[i] = p
+		noise.perm[i+256] = p
+	}
+	return noise
+}
+
+func (noise *Noise) hash(x, y int) int {
+	return noise.perm[noise.perm[x&255]+(y&255)]
+}
+
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp(t, a, b float64) float64 {
+	return a + t*(b-a)
+}
+
+// Value value noise, interpolating between random values at each integer lattice point
+func (noise *Noise) Value(x, y float64) float64 {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	xi, yi := int(x0), int(y0)
+	u, v := fade(x-x0), fade(y-y0)
+
+	value := func(i, j int) float64 {
+		return float64(noise.hash(xi+i, yi+j))/127.5 - 1
+	}
+	return lerp(v, lerp(u, value(0, 0), value(1, 0)), lerp(u, value(0, 1), value(1, 1)))
+}
+
+// perlinGrad the dot product of (x, y) with one of 8 gradient directions
+func perlinGrad(hash int, x, y float64) float64 {
+	switch hash & 7 {
+	case 0:
+		return x + y
+	case 1:
+		return -x + y
+	case 2:
+		return x - y
+	case 3:
+		return -x - y
+	case 4:
+		return x
+	case 5:
+		return -x
+	case 6:
+		return y
+	default:
+		return -y
+	}
+}
+
+// Perlin gradient noise
+// see: https://mrl.cs.nyu.edu/~perlin/noise/
+func (noise *Noise) Perlin(x, y float64) float64 {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	xi, yi := int(x0), int(y0)
+	xf, yf := x-x0, y-y0
+	u, v := fade(xf), fade(yf)
+
+	n00 := perlinGrad(noise.hash(xi, yi), xf, yf)
+	n10 := perlinGrad(noise.hash(xi+1, yi), xf-1, yf)
+	n01 := perlinGrad(noise.hash(xi, yi+1), xf, yf-1)
+	n11 := perlinGrad(noise.hash(xi+1, yi+1), xf-1, yf-1)
+	return lerp(v, lerp(u, n00, n10), lerp(u, n01, n11))
+}
+
+// Simplex 2D simplex noise
+// see: https://weber.itn.liu.se/~stegu/simplexnoise/simplexnoise.pdf
+func (noise *Noise) Simplex(x, y float64) float64 {
+	const (
+		f2 = 0.36602540378443864676 // (sqrt(3) - 1) / 2
+		g2 = 0.21132486540518711775 // (3 - sqrt(3)) / 6
+	)
+
+	// skew the input space to find which simplex cell we are in
+	s := (x + y) * f2
+	i, j := math.Floor(x+s), math.Floor(y+s)
+	t := (i + j) * g2
+	x0, y0 := x-(i-t), y-(j-t)
+
+	// the middle corner of the simplex depends on which triangle of the cell we are in
+	i1, j1 := 0, 1
+	if x0 > y0 {
+		i1, j1 = 1, 0
+	}
+	x1, y1 := x0-float64(i1)+g2, y0-float64(j1)+g2
+	x2, y2 := x0-1+2*g2, y0-1+2*g2
+
+	ii, jj := int(i), int(j)
+	corner := func(hash int, x, y float64) float64 {
+		t := 0.5 - x*x - y*y
+		if t < 0 {
+			return 0
+		}
+		t *= t
+		return t * t * perlinGrad(hash, x, y)
+	}
+	n := corner(noise.hash(ii, jj), x0, y0) +
+		corner(noise.hash(ii+i1, jj+j1), x1, y1) +
+		corner(noise.hash(ii+1, jj+1), x2, y2)
+
+	// scale the result to about [-1, +1]
+	return 70 * n
+}
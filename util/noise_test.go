@@ -0,0 +1,29 @@
+package util
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestNoiseIsDeterministicAndBounded(t *testing.T) {
+	first := NewNoise(rand.New(rand.NewSource(1)))
+	second := NewNoise(rand.New(rand.NewSource(1)))
+
+	functions := map[string][2]func(x, y float64) float64{
+		"value":   {first.Value, second.Value},
+		"perlin":  {first.Perlin, second.Perlin},
+		"simplex": {first.Simplex, second.Simplex},
+	}
+	for name, fns := range functions {
+		for i := 0; i < 1000; i++ {
+			x, y := float64(i%37)*0.37-5, float64(i/37)*0.41-3
+			value := fns[0](x, y)
+			if value != fns[1](x, y) {
+				t.Errorf("%s noise at (%v, %v) differs for the same seed", name, x, y)
+			}
+			if value < -1.0 || value > 1.0 {
+				t.Errorf("%s noise at (%v, %v) is %v, outside [-1, +1]", name, x, y, value)
+			}
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package util
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// PNG text chunks, see: https://www.w3.org/TR/png/#11textinfo
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngIHDRLength the length of the IHDR chunk which begins every PNG: length, type, 13 bytes of data and the CRC
+const pngIHDRLength = 4 + 4 + 13 + 4
+
+// EncodePNG write the given image as a PNG, with a text chunk for each of the given keyword/value pairs
+// values in Latin-1 are written to tEXt chunks, anything else to (uncompressed, UTF-8) iTXt chunks
+func EncodePNG(w io.Writer, img image.Image, text map[string]string) error {
+	if len(text) == 0 {
+		return png.Encode(w, img)
+	}
+
+	encoded := bytes.Buffer{}
+	if err := png.Encode(&encoded, img); err != nil {
+		return err
+	}
+	contents := encoded.Bytes()
+	headerLength := len(pngSignature) + pngIHDRLength
+	if _, err := w.Write(contents[:headerLength]); err != nil {
+		return err
+	}
+
+	keywords := make([]string, 0, len(text))
+	for keyword := range text {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+	for _, keyword := range keywords {
+		if err := writeTextChunk(w, keyword, text[keyword]); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write(contents[headerLength:])
+	return err
+}
+
+// writeTextChunk write the given keyword/value pair as a tEXt chunk or, unless the value is Latin-1, an iTXt chunk
+func writeTextChunk(w io.Writer, keyword, value string) error {
+	if len(keyword) < 1 || len(keyword) > 79 || !isLatin1(keyword) {
+		return fmt.Errorf("invalid PNG text keyword %q: it must be 1-79 Latin-1 characters", keyword)
+	}
+
+	data := bytes.Buffer{}
+	data.WriteString(keyword)
+	data.WriteByte(0)
+	if isLatin1(value) {
+		data.WriteString(value)
+		return writeChunk(w, "tEXt", data.Bytes())
+	}
+	// compression flag and method, then empty language tag and translated keyword
+	data.Write([]byte{0, 0, 0, 0})
+	data.WriteString(value)
+	return writeChunk(w, "iTXt", data.Bytes())
+}
+
+// isLatin1 are all of the characters of the given string printable ASCII, or newlines?
+// NB: Go strings are UTF-8, so the upper half of Latin-1 is written as iTXt
+func isLatin1(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if (s[i] < 0x20 && s[i] != '\n') || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func writeChunk(w io.Writer, chunkType string, data []byte) error {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	copy(header[4:], chunkType)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	footer := make([]byte, 4)
+	binary.BigEndian.PutUint32(footer, crc.Sum32())
+
+	for _, b := range [][]byte{header, data, footer} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadPNGText return the keyword/value pairs of every tEXt, zTXt and iTXt chunk in the given PNG file
+func ReadPNGText(filename string) (map[string]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return DecodePNGText(f)
+}
+
+// DecodePNGText return the keyword/value pairs of every tEXt, zTXt and iTXt chunk in the given PNG
+func DecodePNGText(r io.Reader) (map[string]string, error) {
+	signature := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(r, signature); err != nil || !bytes.Equal(signature, pngSignature) {
+		return nil, errors.New("not a PNG file")
+	}
+
+	text := map[string]string{}
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, fmt.Errorf("truncated PNG file: %v", err)
+		}
+		length := binary.BigEndian.Uint32(header)
+		chunkType := string(header[4:])
+		if chunkType == "IEND" {
+			return text, nil
+		}
+
+		switch chunkType {
+		case "tEXt", "zTXt", "iTXt":
+			data := make([]byte, length)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, fmt.Errorf("truncated PNG %s chunk: %v", chunkType, err)
+			}
+			keyword, value, err := decodeTextChunk(chunkType, data)
+			if err != nil {
+				return nil, err
+			}
+			text[keyword] = value
+		default:
+			if _, err := io.CopyN(ioutil.Discard, r, int64(length)); err != nil {
+				return nil, fmt.Errorf("truncated PNG %s chunk: %v", chunkType, err)
+			}
+		}
+		// skip the CRC
+		if _, err := io.CopyN(ioutil.Discard, r, 4); err != nil {
+			return nil, fmt.Errorf("truncated PNG %s chunk: %v", chunkType, err)
+		}
+	}
+}
+
+// decodeTextChunk return the keyword and value of the given tEXt, zTXt or iTXt chunk data
+func decodeTextChunk(chunkType string, data []byte) (string, string, error) {
+	invalid := fmt.Errorf("invalid PNG %s chunk", chunkType)
+	fields := bytes.SplitN(data, []byte{0}, 2)
+	if len(fields) != 2 {
+		return "", "", invalid
+	}
+	keyword, rest := string(fields[0]), fields[1]
+
+	switch chunkType {
+	case "tEXt":
+		return keyword, latin1ToString(rest), nil
+	case "zTXt":
+		// compression method, then compressed text
+		if len(rest) < 1 {
+			return "", "", invalid
+		}
+		value, err := inflate(rest[1:])
+		return keyword, latin1ToString(value), err
+	default:
+		// compression flag and method, language tag, translated keyword, then text
+		if len(rest) < 2 {
+			return "", "", invalid
+		}
+		compressed := rest[0] == 1
+		fields = bytes.SplitN(rest[2:], []byte{0}, 3)
+		if len(fields) != 3 {
+			return "", "", invalid
+		}
+		value := fields[2]
+		if compressed {
+			var err error
+			if value, err = inflate(value); err != nil {
+				return "", "", err
+			}
+		}
+		return keyword, string(value), nil
+	}
+}
+
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func latin1ToString(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
@@ -0,0 +1,52 @@
+package util
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPNGTextRoundTrip(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(1, 1, color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+	text := map[string]string{
+		"Config":  "{\n  \"Width\": 3\n}",
+		"Seed":    "42",
+		"Comment": "crème brûlée",
+	}
+
+	encoded := bytes.Buffer{}
+	if err := EncodePNG(&encoded, img, text); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodePNGText(bytes.NewReader(encoded.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, text) {
+		t.Errorf("decoded text %q, but expected %q", decoded, text)
+	}
+
+	// the image itself should be unchanged
+	decodedImg, err := png.Decode(bytes.NewReader(encoded.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decodedImg.At(1, 1) != img.At(1, 1) {
+		t.Errorf("decoded pixel %v, but expected %v", decodedImg.At(1, 1), img.At(1, 1))
+	}
+}
+
+func TestPNGTextRejectsInvalidKeywords(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	for _, keyword := range []string{"", "clé", strings.Repeat("k", 80)} {
+		if err := EncodePNG(&bytes.Buffer{}, img, map[string]string{keyword: "value"}); err == nil {
+			t.Errorf("keyword %q should be rejected", keyword)
+		}
+	}
+}
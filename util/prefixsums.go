@@ -0,0 +1,110 @@
+package util
+
+import (
+	"math"
+)
+
+// SummedAreaTable the sum of every rectangle of a grid, from its top left corner
+// see: https://en.wikipedia.org/wiki/Summed-area_table
+//
+// this allows the sum (or average) of any rectangle of the grid to be
+// calculated in O(1), whatever its size
+type SummedAreaTable struct {
+	Width  int
+	Height int
+	sums   [][]float64 // sums[y][x] is the sum of all grid values above and left of (x, y)
+}
+
+// MakeSummedAreaTable make a summed area table from the given grid, indexed [y][x]
+func MakeSummedAreaTable(grid [][]float64) *SummedAreaTable {
+	height := len(grid)
+	width := len(grid[0])
+	sat := &SummedAreaTable{
+		Width:  width,
+		Height: height,
+		sums:   Make2DGridFloat64(width+1, height+1),
+	}
+	for y := 0; y < height; y++ {
+		rowSum := 0.0
+		for x := 0; x < width; x++ {
+			rowSum += grid[y][x]
+			sat.sums[y+1][x+1] = sat.sums[y][x+1] + rowSum
+		}
+	}
+	return sat
+}
+
+// Sum return the sum of all grid values within the rectangle (x0, y0) to (x1, y1) inclusive
+// NB: the rectangle is clipped to the bounds of the grid
+func (sat *SummedAreaTable) Sum(x0, y0, x1, y1 int) float64 {
+	x0 = ConstrainInt(0, x0, sat.Width)
+	y0 = ConstrainInt(0, y0, sat.Height)
+	x1 = ConstrainInt(0, x1+1, sat.Width)
+	y1 = ConstrainInt(0, y1+1, sat.Height)
+	if x1 <= x0 || y1 <= y0 {
+		return 0.0
+	}
+	return sat.sums[y1][x1] - sat.sums[y0][x1] - sat.sums[y1][x0] + sat.sums[y0][x0]
+}
+
+// AverageOfPixelsWithinSquare return average of all pixel values in the square
+// centred on x, y and extending radius pixels either side
+// NB: only pixels within the bounds of the grid are included
+func (sat *SummedAreaTable) AverageOfPixelsWithinSquare(x, y, radius int) float64 {
+	x0, x1 := ConstrainInt(0, x-radius, sat.Width-1), ConstrainInt(0, x+radius, sat.Width-1)
+	y0, y1 := ConstrainInt(0, y-radius, sat.Height-1), ConstrainInt(0, y+radius, sat.Height-1)
+	count := (x1 - x0 + 1) * (y1 - y0 + 1)
+	return sat.Sum(x0, y0, x1, y1) / float64(count)
+}
+
+// RowPrefixSums the running sum along every row of a grid
+//
+// this allows the sum (or average) of any circle of the grid to be
+// calculated in O(radius), one span of each row at a time
+type RowPrefixSums struct {
+	Width  int
+	Height int
+	sums   [][]float64 // sums[y][x] is the sum of grid values [0, x) along row y
+}
+
+// MakeRowPrefixSums make row prefix sums from the given grid, indexed [y][x]
+func MakeRowPrefixSums(grid [][]float64) *RowPrefixSums {
+	height := len(grid)
+	width := len(grid[0])
+	rps := &RowPrefixSums{
+		Width:  width,
+		Height: height,
+		sums:   Make2DGridFloat64(width+1, height),
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			rps.sums[y][x+1] = rps.sums[y][x] + grid[y][x]
+		}
+	}
+	return rps
+}
+
+// AverageOfPixelsWithinCircle return average of all pixel values in the given circle
+// i.e. every pixel for which PointIsWithinCircle is true
+// NB: only pixels within the bounds of the grid are included
+func (rps *RowPrefixSums) AverageOfPixelsWithinCircle(x, y, radius int) float64 {
+	sum := 0.0
+	count := 0
+
+	y0 := ConstrainInt(0, y-radius, rps.Height)
+	y1 := ConstrainInt(0, y+radius+1, rps.Height)
+	for j := y0; j < y1; j++ {
+		// the half-width of the circle along this row
+		halfWidth := int(math.Sqrt(float64(sqr(radius) - sqr(j-y))))
+		x0 := ConstrainInt(0, x-halfWidth, rps.Width)
+		x1 := ConstrainInt(0, x+halfWidth+1, rps.Width)
+		if x0 < x1 {
+			sum += rps.sums[j][x1] - rps.sums[j][x0]
+			count += x1 - x0
+		}
+	}
+	if count == 0 {
+		return 0.0
+	}
+	return sum / float64(count)
+}
@@ -0,0 +1,63 @@
+package util
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// bruteAverageWithinSquare the O(r²) reference for SummedAreaTable.AverageOfPixelsWithinSquare
+func bruteAverageWithinSquare(x, y, radius int, grid [][]float64) float64 {
+	sum, count := 0.0, 0
+	for j := y - radius; j <= y+radius; j++ {
+		for i := x - radius; i <= x+radius; i++ {
+			if j >= 0 && j < len(grid) && i >= 0 && i < len(grid[0]) {
+				sum += grid[j][i]
+				count++
+			}
+		}
+	}
+	return sum / float64(count)
+}
+
+func TestSummedAreaTableSum(t *testing.T) {
+	grid := Make2DGridFloat64(4, 3)
+	for y := range grid {
+		for x := range grid[y] {
+			grid[y][x] = 1.0
+		}
+	}
+	sat := MakeSummedAreaTable(grid)
+	if sum := sat.Sum(0, 0, 3, 2); sum != 12.0 {
+		t.Errorf("sum of the whole grid is %v, but it should be %v", sum, 12.0)
+	}
+	if sum := sat.Sum(1, 1, 2, 2); sum != 4.0 {
+		t.Errorf("sum of (1, 1) to (2, 2) is %v, but it should be %v", sum, 4.0)
+	}
+	if sum := sat.Sum(-5, -5, 0, 0); sum != 1.0 {
+		t.Errorf("sum of (-5, -5) to (0, 0) is %v, but it should be %v", sum, 1.0)
+	}
+}
+
+func TestPrefixSumAveragesAgreeWithBruteForce(t *testing.T) {
+	width, height := 41, 33
+	grid := Make2DGridFloat64Randomised(rand.New(rand.NewSource(1)), width, height)
+	sat := MakeSummedAreaTable(grid)
+	rps := MakeRowPrefixSums(grid)
+
+	for _, radius := range []int{0, 1, 2, 5, 17, 50} {
+		for y := 0; y < height; y += 3 {
+			for x := 0; x < width; x += 4 {
+				expected := AverageOfPixelsWithinCircle(x, y, radius, grid)
+				if average := rps.AverageOfPixelsWithinCircle(x, y, radius); math.Abs(average-expected) > 1e-9 {
+					t.Errorf("circle(x:%d, y:%d, radius:%d) average is %v, but it should be %v", x, y, radius, average, expected)
+				}
+
+				expected = bruteAverageWithinSquare(x, y, radius, grid)
+				if average := sat.AverageOfPixelsWithinSquare(x, y, radius); math.Abs(average-expected) > 1e-9 {
+					t.Errorf("square(x:%d, y:%d, radius:%d) average is %v, but it should be %v", x, y, radius, average, expected)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package util
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+)
+
+// color quantisation methods
+const (
+	QuantizeMedianCut = "median-cut" // split the box of colors with the widest range at its median, see: https://en.wikipedia.org/wiki/Median_cut
+	QuantizeOctree    = "octree"     // merge the least common leaves of an octree of colors, see: https://en.wikipedia.org/wiki/Octree#Color_quantization
+)
+
+// rgb an opaque color
+type rgb [3]uint8
+
+// colorCount a color and the number of pixels of that color
+type colorCount struct {
+	color rgb
+	count int
+}
+
+// ColorHistogram the number of pixels of each color, in one or more images; alpha is ignored
+type ColorHistogram map[rgb]int
+
+// Add count the pixels of the given image
+func (h ColorHistogram) Add(img image.Image) {
+	bounds := img.Bounds()
+	if nrgba, ok := img.(*image.NRGBA); ok {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			row := nrgba.Pix[nrgba.PixOffset(bounds.Min.X, y):]
+			for x := 0; x < bounds.Dx(); x++ {
+				h[rgb{row[4*x], row[4*x+1], row[4*x+2]}]++
+			}
+		}
+		return
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			h[rgbOf(img.At(x, y))]++
+		}
+	}
+}
+
+// colors return every color of this histogram, in a fixed order so that quantisation is repeatable
+func (h ColorHistogram) colors() []colorCount {
+	colors := make([]colorCount, 0, len(h))
+	for c, count := range h {
+		colors = append(colors, colorCount{c, count})
+	}
+	sort.Slice(colors, func(i, j int) bool {
+		a, b := colors[i].color, colors[j].color
+		return (a[0] < b[0]) || (a[0] == b[0] && (a[1] < b[1] || (a[1] == b[1] && a[2] < b[2])))
+	})
+	return colors
+}
+
+func rgbOf(c color.Color) rgb {
+	r, g, b, _ := color.NRGBAModel.Convert(c).RGBA()
+	return rgb{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)}
+}
+
+// Quantize return a palette of at most n colors which represents the colors of the given histogram
+// method: QuantizeMedianCut or QuantizeOctree
+func Quantize(h ColorHistogram, n int, method string) (color.Palette, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("invalid palette size %d", n)
+	}
+	quantize := medianCut
+	switch method {
+	case QuantizeMedianCut:
+	case QuantizeOctree:
+		quantize = octreeQuantize
+	default:
+		return nil, fmt.Errorf("unknown quantisation method %q, expected %q or %q", method, QuantizeMedianCut, QuantizeOctree)
+	}
+
+	colors := h.colors()
+	if len(colors) == 0 {
+		return color.Palette{color.Black}, nil
+	}
+	return quantize(colors, n), nil
+}
+
+// meanColor the mean of the given colors, weighted by their counts
+func meanColor(colors []colorCount) color.Color {
+	sums, total := [3]int{}, 0
+	for _, c := range colors {
+		for ch := range sums {
+			sums[ch] += int(c.color[ch]) * c.count
+		}
+		total += c.count
+	}
+	mean := color.NRGBA{A: 255}
+	mean.R = uint8((sums[0] + total/2) / total)
+	mean.G = uint8((sums[1] + total/2) / total)
+	mean.B = uint8((sums[2] + total/2) / total)
+	return mean
+}
+
+// widestChannel the channel, and its range, in which the given colors vary the most
+func widestChannel(colors []colorCount) (int, int) {
+	widest, widestRange := 0, -1
+	for ch := 0; ch < 3; ch++ {
+		min, max := 255, 0
+		for _, c := range colors {
+			if int(c.color[ch]) < min {
+				min = int(c.color[ch])
+			}
+			if int(c.color[ch]) > max {
+				max = int(c.color[ch])
+			}
+		}
+		if max-min > widestRange {
+			widest, widestRange = ch, max-min
+		}
+	}
+	return widest, widestRange
+}
+
+func medianCut(colors []colorCount, n int) color.Palette {
+	boxes := [][]colorCount{colors}
+	for len(boxes) < n {
+		// split the box with the widest range of colors
+		split, splitChannel, splitRange := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			ch, r := widestChannel(box)
+			if r > splitRange {
+				split, splitChannel, splitRange = i, ch, r
+			}
+		}
+		if split < 0 {
+			break
+		}
+
+		box := boxes[split]
+		sort.SliceStable(box, func(i, j int) bool {
+			return box[i].color[splitChannel] < box[j].color[splitChannel]
+		})
+		total := 0
+		for _, c := range box {
+			total += c.count
+		}
+		// the weighted median, leaving at least one color on each side
+		median, cumulative := 1, box[0].count
+		for median < len(box)-1 && cumulative < total/2 {
+			cumulative += box[median].count
+			median++
+		}
+		boxes[split] = box[:median]
+		boxes = append(boxes, box[median:])
+	}
+
+	palette := make(color.Palette, len(boxes))
+	for i, box := range boxes {
+		palette[i] = meanColor(box)
+	}
+	return palette
+}
+
+// octreeDepth the number of bits of each channel distinguished by an octree
+const octreeDepth = 8
+
+type octreeNode struct {
+	sums     [3]int // the sums and count of every pixel within this node and its descendants
+	count    int
+	children [8]*octreeNode
+	isLeaf   bool
+}
+
+type octree struct {
+	root   *octreeNode
+	levels [octreeDepth][]*octreeNode // the nodes of each level which have children
+	leaves int
+}
+
+func (tree *octree) insert(c colorCount) {
+	node := tree.root
+	for level := 0; ; level++ {
+		for ch := range node.sums {
+			node.sums[ch] += int(c.color[ch]) * c.count
+		}
+		node.count += c.count
+		if node.isLeaf {
+			return
+		}
+
+		bit := uint(octreeDepth - 1 - level)
+		ndx := (c.color[0]>>bit&1)<<2 | (c.color[1]>>bit&1)<<1 | (c.color[2] >> bit & 1)
+		if node.children[ndx] == nil {
+			child := &octreeNode{isLeaf: level+1 == octreeDepth}
+			if child.isLeaf {
+				tree.leaves++
+			} else {
+				tree.levels[level+1] = append(tree.levels[level+1], child)
+			}
+			node.children[ndx] = child
+		}
+		node = node.children[ndx]
+	}
+}
+
+// reduce merge the least common nodes of each level into leaves, deepest level first, until at most n leaves remain
+// NB: merging a node never adds to its own level, so each level need only be sorted once
+func (tree *octree) reduce(n int) {
+	for level := octreeDepth - 1; level >= 0 && tree.leaves > n; level-- {
+		nodes := tree.levels[level]
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return nodes[i].count < nodes[j].count
+		})
+
+		merged := 0
+		for ; merged < len(nodes) && tree.leaves > n; merged++ {
+			node := nodes[merged]
+			for i, child := range node.children {
+				if child != nil {
+					node.children[i] = nil
+					tree.leaves--
+				}
+			}
+			node.isLeaf = true
+			tree.leaves++
+		}
+		tree.levels[level] = nodes[merged:]
+	}
+}
+
+func (node *octreeNode) appendLeafColors(palette color.Palette) color.Palette {
+	if node.isLeaf {
+		return append(palette, color.NRGBA{
+			R: uint8((node.sums[0] + node.count/2) / node.count),
+			G: uint8((node.sums[1] + node.count/2) / node.count),
+			B: uint8((node.sums[2] + node.count/2) / node.count),
+			A: 255,
+		})
+	}
+	for _, child := range node.children {
+		if child != nil {
+			palette = child.appendLeafColors(palette)
+		}
+	}
+	return palette
+}
+
+func octreeQuantize(colors []colorCount, n int) color.Palette {
+	tree := octree{root: &octreeNode{}}
+	tree.levels[0] = []*octreeNode{tree.root}
+	for _, c := range colors {
+		tree.insert(c)
+	}
+	tree.reduce(n)
+	return tree.root.appendLeafColors(nil)
+}
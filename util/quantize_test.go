@@ -0,0 +1,130 @@
+package util
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// gradientImage an image with a wide range of colors
+func gradientImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 255 / width), G: uint8(y * 255 / height), B: uint8((x + y) * 127 / (width + height)), A: 255})
+		}
+	}
+	return img
+}
+
+func colorDistance(a, b color.Color) float64 {
+	ca, cb := rgbOf(a), rgbOf(b)
+	distance := 0.0
+	for ch := range ca {
+		d := float64(ca[ch]) - float64(cb[ch])
+		distance += d * d
+	}
+	return distance
+}
+
+func TestQuantizeKeepsFewColorsExactly(t *testing.T) {
+	colors := []color.NRGBA{{255, 0, 0, 255}, {0, 128, 0, 255}, {10, 20, 30, 255}}
+	histogram := ColorHistogram{}
+	for i, c := range colors {
+		histogram[rgbOf(c)] = i + 1
+	}
+	for _, method := range []string{QuantizeMedianCut, QuantizeOctree} {
+		palette, err := Quantize(histogram, 16, method)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(palette) != len(colors) {
+			t.Errorf("%s: %d colors, but expected %d", method, len(palette), len(colors))
+		}
+		for _, c := range colors {
+			if d := colorDistance(c, palette.Convert(c)); d != 0 {
+				t.Errorf("%s: %v should be in the palette, but the nearest is %v", method, c, palette.Convert(c))
+			}
+		}
+	}
+}
+
+func TestQuantizeApproximatesManyColors(t *testing.T) {
+	img := gradientImage(64, 64)
+	histogram := ColorHistogram{}
+	histogram.Add(img)
+
+	for _, method := range []string{QuantizeMedianCut, QuantizeOctree} {
+		palette, err := Quantize(histogram, 64, method)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(palette) > 64 {
+			t.Errorf("%s: %d colors, but expected at most 64", method, len(palette))
+		}
+
+		total := 0.0
+		for y := 0; y < 64; y++ {
+			for x := 0; x < 64; x++ {
+				total += colorDistance(img.At(x, y), palette.Convert(img.At(x, y)))
+			}
+		}
+		// an RMS error of 16 is a sixteenth of the range of each channel
+		if meanSquared := total / (64 * 64); meanSquared > 16*16 {
+			t.Errorf("%s: mean squared error %.1f is too large", method, meanSquared)
+		}
+	}
+}
+
+func TestQuantizeRejectsUnknownMethods(t *testing.T) {
+	if _, err := Quantize(ColorHistogram{}, 16, "k-means"); err == nil {
+		t.Errorf("an unknown method should be rejected")
+	}
+}
+
+// manyColorsHistogram the histogram of a 256x256 image, every pixel of which is a distinct color
+func manyColorsHistogram() ColorHistogram {
+	img := image.NewNRGBA(image.Rect(0, 0, 256, 256))
+	for y := 0; y < 256; y++ {
+		for x := 0; x < 256; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: uint8(x ^ y), A: 255})
+		}
+	}
+	histogram := ColorHistogram{}
+	histogram.Add(img)
+	return histogram
+}
+
+func TestQuantizeManyColors(t *testing.T) {
+	histogram := manyColorsHistogram()
+	if len(histogram) != 256*256 {
+		t.Fatalf("%d colors, but expected %d", len(histogram), 256*256)
+	}
+	for _, method := range []string{QuantizeMedianCut, QuantizeOctree} {
+		palette, err := Quantize(histogram, 256, method)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(palette) == 0 || len(palette) > 256 {
+			t.Errorf("%s: %d colors, but expected between 1 and 256", method, len(palette))
+		}
+	}
+}
+
+func benchmarkQuantize(b *testing.B, method string) {
+	histogram := manyColorsHistogram()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Quantize(histogram, 256, method); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkQuantizeMedianCut(b *testing.B) {
+	benchmarkQuantize(b, QuantizeMedianCut)
+}
+
+func BenchmarkQuantizeOctree(b *testing.B) {
+	benchmarkQuantize(b, QuantizeOctree)
+}
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"math/rand"
+)
+
+// RandSource a seeded source of random numbers which counts the values it has generated
+// so that its position can be recorded, then restored by skipping that many values
+type RandSource struct {
+	seed  int64
+	draws uint64
+	src   rand.Source64
+}
+
+// NewRandSource return a RandSource seeded with the given seed
+func NewRandSource(seed int64) *RandSource {
+	return &RandSource{seed: seed, src: rand.NewSource(seed).(rand.Source64)}
+}
+
+// NewRand return a random number generator, and its source, seeded with the given seed
+func NewRand(seed int64) (*rand.Rand, *RandSource) {
+	src := NewRandSource(seed)
+	return rand.New(src), src
+}
+
+// Int63 return a non-negative random int64
+func (s *RandSource) Int63() int64 {
+	s.draws++
+	return s.src.Int63()
+}
+
+// Uint64 return a random uint64
+func (s *RandSource) Uint64() uint64 {
+	s.draws++
+	return s.src.Uint64()
+}
+
+// Seed restart this source from the given seed
+func (s *RandSource) Seed(seed int64) {
+	s.seed = seed
+	s.draws = 0
+	s.src.Seed(seed)
+}
+
+// InitialSeed return the seed this source was started from
+func (s *RandSource) InitialSeed() int64 {
+	return s.seed
+}
+
+// Draws return the number of values generated since this source was seeded
+func (s *RandSource) Draws() uint64 {
+	return s.draws
+}
+
+// Skip generate (and discard) the given number of values
+func (s *RandSource) Skip(draws uint64) {
+	for i := uint64(0); i < draws; i++ {
+		s.Int63()
+	}
+}
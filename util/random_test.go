@@ -0,0 +1,23 @@
+package util
+
+import (
+	"testing"
+)
+
+func TestRandSourceSkipRestoresPosition(t *testing.T) {
+	rng, src := NewRand(1234)
+	for i := 0; i < 100; i++ {
+		RandFloat64(rng, -1.0, 1.0)
+	}
+	rng.Uint64()
+
+	restoredRng, restoredSrc := NewRand(src.InitialSeed())
+	restoredSrc.Skip(src.Draws())
+
+	for i := 0; i < 10; i++ {
+		expected, actual := rng.Float64(), restoredRng.Float64()
+		if expected != actual {
+			t.Errorf("value %d after skipping %d draws is %v, but it should be %v", i, src.Draws(), actual, expected)
+		}
+	}
+}
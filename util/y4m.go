@@ -0,0 +1,163 @@
+package util
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+)
+
+// YUV4MPEG2 video, see: https://wiki.multimedia.cx/index.php/YUV4MPEG2
+
+// Y4M chroma subsampling
+const (
+	Chroma420 = "420" // chroma at half the resolution in each direction, which every encoder accepts
+	Chroma444 = "444" // chroma at full resolution
+)
+
+// Y4MWriter streams frames of video, converted to BT.601 YCbCr, to a file or pipe
+type Y4MWriter struct {
+	w      *bufio.Writer
+	closer io.Closer // the file, or nil for a writer (e.g. stdout) which is left open
+	fps    int
+	chroma string
+	bounds image.Rectangle // of the first frame, which every later frame must match
+	frames int
+	planes [3][]byte
+}
+
+// NewY4MWriter begin a video, written to w at the given frame rate
+func NewY4MWriter(w io.Writer, fps int, chroma string) (*Y4MWriter, error) {
+	if fps < 1 {
+		return nil, fmt.Errorf("invalid frame rate %d", fps)
+	}
+	switch chroma {
+	case "":
+		chroma = Chroma420
+	case Chroma420, Chroma444:
+	default:
+		return nil, fmt.Errorf("unknown chroma subsampling %q, expected %q or %q", chroma, Chroma420, Chroma444)
+	}
+	return &Y4MWriter{w: bufio.NewWriterSize(w, 1<<20), fps: fps, chroma: chroma}, nil
+}
+
+// CreateY4M begin a video file, see NewY4MWriter
+func CreateY4M(filename string, fps int, chroma string) (*Y4MWriter, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	y, err := NewY4MWriter(f, fps, chroma)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	y.closer = f
+	return y, nil
+}
+
+// AddFrame append the given image to the video
+// every frame must have the same size as the first
+func (y *Y4MWriter) AddFrame(img image.Image) error {
+	if y.frames == 0 {
+		y.bounds = img.Bounds()
+		if err := y.writeHeader(); err != nil {
+			return err
+		}
+	} else if img.Bounds().Size() != y.bounds.Size() {
+		return errors.New("every video frame must have the same size as the first")
+	}
+
+	y.convert(img)
+	if _, err := y.w.WriteString("FRAME\n"); err != nil {
+		return err
+	}
+	for _, plane := range y.planes {
+		if _, err := y.w.Write(plane); err != nil {
+			return err
+		}
+	}
+	y.frames++
+	return y.w.Flush()
+}
+
+func (y *Y4MWriter) writeHeader() error {
+	colorspace := "C444"
+	if y.chroma == Chroma420 {
+		colorspace = "C420jpeg XYSCSS=420JPEG"
+	}
+	_, err := fmt.Fprintf(y.w, "YUV4MPEG2 W%d H%d F%d:1 Ip A1:1 %s XCOLORRANGE=LIMITED\n", y.bounds.Dx(), y.bounds.Dy(), y.fps, colorspace)
+	return err
+}
+
+// chromaSize the width and height of the chroma planes
+func (y *Y4MWriter) chromaSize() (int, int) {
+	width, height := y.bounds.Dx(), y.bounds.Dy()
+	if y.chroma == Chroma420 {
+		return (width + 1) / 2, (height + 1) / 2
+	}
+	return width, height
+}
+
+// convert the given image into the Y, Cb and Cr planes
+func (y *Y4MWriter) convert(img image.Image) {
+	width, height := y.bounds.Dx(), y.bounds.Dy()
+	chromaWidth, chromaHeight := y.chromaSize()
+	if y.planes[0] == nil {
+		y.planes[0] = make([]byte, width*height)
+		y.planes[1] = make([]byte, chromaWidth*chromaHeight)
+		y.planes[2] = make([]byte, chromaWidth*chromaHeight)
+	}
+
+	// the chroma of each pixel is summed into its (possibly subsampled) chroma sample
+	cbSums := make([]float64, chromaWidth*chromaHeight)
+	crSums := make([]float64, chromaWidth*chromaHeight)
+	counts := make([]float64, chromaWidth*chromaHeight)
+	bounds := img.Bounds()
+	for j := 0; j < height; j++ {
+		for i := 0; i < width; i++ {
+			c := rgbOf(img.At(bounds.Min.X+i, bounds.Min.Y+j))
+			luma, cb, cr := bt601(c)
+			y.planes[0][j*width+i] = clampByte(luma)
+
+			ndx := j*chromaWidth + i
+			if y.chroma == Chroma420 {
+				ndx = (j/2)*chromaWidth + i/2
+			}
+			cbSums[ndx] += cb
+			crSums[ndx] += cr
+			counts[ndx]++
+		}
+	}
+	for ndx := range counts {
+		y.planes[1][ndx] = clampByte(cbSums[ndx] / counts[ndx])
+		y.planes[2][ndx] = clampByte(crSums[ndx] / counts[ndx])
+	}
+}
+
+// bt601 convert the given color to limited range BT.601 YCbCr
+// see: https://en.wikipedia.org/wiki/YCbCr#ITU-R_BT.601_conversion
+func bt601(c rgb) (float64, float64, float64) {
+	r, g, b := float64(c[0])/255, float64(c[1])/255, float64(c[2])/255
+	luma := 16 + 65.481*r + 128.553*g + 24.966*b
+	cb := 128 - 37.797*r - 74.203*g + 112.0*b
+	cr := 128 + 112.0*r - 93.786*g - 18.214*b
+	return luma, cb, cr
+}
+
+func clampByte(v float64) byte {
+	return byte(Constrain(0, v+0.5, 255))
+}
+
+// Close flush the video, closing its file (but not a writer such as stdout)
+func (y *Y4MWriter) Close() error {
+	err := y.w.Flush()
+	if y.closer != nil {
+		if closeErr := y.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestY4M(t *testing.T) {
+	width, height := 5, 3
+	white, black := image.NewNRGBA(image.Rect(0, 0, width, height)), image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			white.SetNRGBA(x, y, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+			black.SetNRGBA(x, y, color.NRGBA{A: 255})
+		}
+	}
+
+	for _, test := range []struct {
+		chroma                    string
+		colorspace                string
+		chromaWidth, chromaHeight int
+	}{
+		{Chroma420, "C420jpeg", 3, 2},
+		{Chroma444, "C444", 5, 3},
+	} {
+		buf := bytes.Buffer{}
+		y4m, err := NewY4MWriter(&buf, 30, test.chroma)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, frame := range []image.Image{white, black} {
+			if err := y4m.AddFrame(frame); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := y4m.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		contents := buf.String()
+		header := contents[:strings.Index(contents, "\n")+1]
+		if !strings.HasPrefix(header, fmt.Sprintf("YUV4MPEG2 W%d H%d F30:1 Ip A1:1 %s", width, height, test.colorspace)) {
+			t.Errorf("%s: unexpected header %q", test.chroma, header)
+		}
+
+		frameSize := len("FRAME\n") + width*height + 2*test.chromaWidth*test.chromaHeight
+		frames := contents[len(header):]
+		if len(frames) != 2*frameSize {
+			t.Fatalf("%s: %d bytes of frames, but expected %d", test.chroma, len(frames), 2*frameSize)
+		}
+		// limited range: white is Y 235, black is Y 16, and neither has any chroma
+		for i, expectedLuma := range []byte{235, 16} {
+			frame := frames[i*frameSize : (i+1)*frameSize]
+			if !strings.HasPrefix(frame, "FRAME\n") {
+				t.Errorf("%s: frame %d has no FRAME header", test.chroma, i)
+			}
+			planes := frame[len("FRAME\n"):]
+			if planes[0] != expectedLuma || planes[width*height] != 128 || planes[len(planes)-1] != 128 {
+				t.Errorf("%s: frame %d has Y %d, Cb %d, Cr %d, but expected %d, 128, 128",
+					test.chroma, i, planes[0], planes[width*height], planes[len(planes)-1], expectedLuma)
+			}
+		}
+	}
+}